@@ -0,0 +1,263 @@
+// Package admission controls connections accepted by gates, which limits the
+// total sessions and connections per IP or network, filters addresses by allow
+// and deny lists, and calls a hook for custom decisions.
+package admission
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons of rejected connections
+var (
+	ErrDenied              = errors.New("admission: address denied")
+	ErrTooManySessions     = errors.New("admission: too many sessions")
+	ErrTooManyConnsPerIP   = errors.New("admission: too many connections from ip")
+	ErrTooManyConnsNetwork = errors.New("admission: too many connections from network")
+)
+
+// Reason returns the short reason of the error returned by Admit, errors of
+// the Accept hook are reported as "hook"
+func Reason(err error) string {
+	switch err {
+	case ErrDenied:
+		return "denied"
+	case ErrTooManySessions:
+		return "max_sessions"
+	case ErrTooManyConnsPerIP:
+		return "max_conns_per_ip"
+	case ErrTooManyConnsNetwork:
+		return "max_conns_network"
+	}
+	return "hook"
+}
+
+// Config is the config of admission, zero values mean unlimited
+type Config struct {
+	MaxSessions   int
+	MaxConnsPerIP int
+	// NetworkLimits limits connections from networks, such as:
+	// {"10.0.0.0/8": 1000}
+	NetworkLimits map[string]int
+
+	// Allow accepts connections from the IPs or networks only if not empty,
+	// Deny rejects connections from the IPs or networks
+	Allow []string
+	Deny  []string
+
+	// HandshakeTimeout closes connections which do not send a valid first
+	// packet in time
+	HandshakeTimeout time.Duration
+
+	// Accept is called at last for custom decisions, the connection is
+	// rejected if it returns an error
+	Accept func(conn net.Conn) error
+}
+
+type networkLimit struct {
+	network *net.IPNet
+	max     int
+	count   int
+}
+
+// Controller admits connections by the config
+type Controller struct {
+	config Config
+	allow  []*net.IPNet
+	deny   []*net.IPNet
+
+	mu       sync.Mutex
+	sessions int
+	perIP    map[string]int
+	networks []*networkLimit
+}
+
+// parseNetwork parses a CIDR or an IP as a single address network
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: s}
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func parseNetworks(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		network, err := parseNetwork(s)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns a controller, it returns an error if an address is invalid
+func New(config Config) (*Controller, error) {
+	c := &Controller{config: config, perIP: map[string]int{}}
+
+	var err error
+	if c.allow, err = parseNetworks(config.Allow); err != nil {
+		return nil, err
+	}
+	if c.deny, err = parseNetworks(config.Deny); err != nil {
+		return nil, err
+	}
+	for cidr, max := range config.NetworkLimits {
+		network, err := parseNetwork(cidr)
+		if err != nil {
+			return nil, err
+		}
+		c.networks = append(c.networks, &networkLimit{network: network, max: max})
+	}
+	return c, nil
+}
+
+// HandshakeTimeout returns the timeout of the first packet
+func (c *Controller) HandshakeTimeout() time.Duration {
+	return c.config.HandshakeTimeout
+}
+
+// addrIP returns the IP of address, or nil if the address is not an IP
+// address, such as in-memory pipes
+func addrIP(addr net.Addr) net.IP {
+	if addr == nil {
+		return nil
+	}
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+// CheckAddr checks the address by allow and deny lists
+func (c *Controller) CheckAddr(addr net.Addr) error {
+	ip := addrIP(addr)
+	if len(c.allow) > 0 && (ip == nil || !contains(c.allow, ip)) {
+		return ErrDenied
+	}
+	if ip != nil && contains(c.deny, ip) {
+		return ErrDenied
+	}
+	return nil
+}
+
+// Accept calls the Accept hook of config if set
+func (c *Controller) Accept(conn net.Conn) error {
+	if c.config.Accept == nil {
+		return nil
+	}
+	return c.config.Accept(conn)
+}
+
+// Admit decides whether to accept the connection, release should be called
+// after the admitted connection is closed
+func (c *Controller) Admit(conn net.Conn) (release func(), err error) {
+	if err := c.CheckAddr(conn.RemoteAddr()); err != nil {
+		return nil, err
+	}
+	if err := c.Accept(conn); err != nil {
+		return nil, err
+	}
+	return c.reserve(addrIP(conn.RemoteAddr()))
+}
+
+// AdmitAddr is like Admit for connections not established yet, such as HTTP
+// requests before upgraded, so that rejected requests are not authenticated
+// or upgraded. The Accept hook is not called, which should be called by Accept
+// after the connection is established.
+func (c *Controller) AdmitAddr(addr net.Addr) (release func(), err error) {
+	if err := c.CheckAddr(addr); err != nil {
+		return nil, err
+	}
+	return c.reserve(addrIP(addr))
+}
+
+// reserve takes a slot of the limits for the IP
+func (c *Controller) reserve(ip net.IP) (release func(), err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.config.MaxSessions > 0 && c.sessions >= c.config.MaxSessions {
+		return nil, ErrTooManySessions
+	}
+
+	var key string
+	var networks []*networkLimit
+	if ip != nil {
+		key = ip.String()
+		if c.config.MaxConnsPerIP > 0 && c.perIP[key] >= c.config.MaxConnsPerIP {
+			return nil, ErrTooManyConnsPerIP
+		}
+		for _, n := range c.networks {
+			if !n.network.Contains(ip) {
+				continue
+			}
+			if n.max > 0 && n.count >= n.max {
+				return nil, ErrTooManyConnsNetwork
+			}
+			networks = append(networks, n)
+		}
+	}
+
+	c.sessions++
+	if ip != nil {
+		c.perIP[key]++
+	}
+	for _, n := range networks {
+		n.count++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			c.sessions--
+			if ip != nil {
+				if c.perIP[key]--; c.perIP[key] <= 0 {
+					delete(c.perIP, key)
+				}
+			}
+			for _, n := range networks {
+				n.count--
+			}
+		})
+	}, nil
+}
+
+// Sessions returns the count of admitted connections not released
+func (c *Controller) Sessions() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessions
+}
@@ -0,0 +1,127 @@
+package admission
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+type conn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c *conn) RemoteAddr() net.Addr { return c.addr }
+
+func dial(addr string) net.Conn {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	return &conn{addr: tcpAddr}
+}
+
+func TestController(t *testing.T) {
+	errBanned := errors.New("banned")
+	c, err := New(Config{
+		MaxSessions:   4,
+		MaxConnsPerIP: 2,
+		NetworkLimits: map[string]int{"10.1.0.0/16": 1},
+		Allow:         []string{"10.0.0.0/8"},
+		Deny:          []string{"10.0.0.9"},
+		Accept: func(conn net.Conn) error {
+			if conn.RemoteAddr().String() == "10.0.0.8:1" {
+				return errBanned
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admit := func(addr string, want error) func() {
+		t.Helper()
+		release, err := c.Admit(dial(addr))
+		if err != want {
+			t.Fatalf("admit %s: got %v, want %v", addr, err, want)
+		}
+		return release
+	}
+
+	admit("192.168.0.1:1", ErrDenied)
+	admit("10.0.0.9:1", ErrDenied)
+	admit("10.0.0.8:1", errBanned)
+	if Reason(errBanned) != "hook" || Reason(ErrDenied) != "denied" {
+		t.Fatal("unexpected reasons")
+	}
+
+	release := admit("10.0.0.1:1", nil)
+	admit("10.0.0.1:2", nil)
+	admit("10.0.0.1:3", ErrTooManyConnsPerIP)
+	release()
+	release()
+	admit("10.0.0.1:3", nil)
+
+	admit("10.1.0.1:1", nil)
+	admit("10.1.0.2:1", ErrTooManyConnsNetwork)
+	admit("10.2.0.1:1", nil)
+	admit("10.3.0.1:1", ErrTooManySessions)
+	if c.Sessions() != 4 {
+		t.Fatalf("%d sessions admitted", c.Sessions())
+	}
+}
+
+func TestAdmitAddr(t *testing.T) {
+	accepted := 0
+	c, err := New(Config{
+		MaxConnsPerIP: 1,
+		Deny:          []string{"10.0.0.9"},
+		Accept: func(conn net.Conn) error {
+			accepted++
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, _ := net.ResolveTCPAddr("tcp", "10.0.0.9:1")
+	if err := c.CheckAddr(addr); err != ErrDenied {
+		t.Fatalf("denied address is checked: %v", err)
+	}
+	if _, err := c.AdmitAddr(addr); err != ErrDenied {
+		t.Fatalf("denied address is admitted: %v", err)
+	}
+
+	addr, _ = net.ResolveTCPAddr("tcp", "10.0.0.1:1")
+	release, err := c.AdmitAddr(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted != 0 {
+		t.Fatal("accept hook is called before the connection is established")
+	}
+	if _, err := c.AdmitAddr(addr); err != ErrTooManyConnsPerIP {
+		t.Fatalf("got %v, want %v", err, ErrTooManyConnsPerIP)
+	}
+	if err := c.CheckAddr(addr); err != nil {
+		t.Fatalf("check is limited: %v", err)
+	}
+	if err := c.Accept(dial("10.0.0.1:1")); err != nil || accepted != 1 {
+		t.Fatalf("accept hook is not called: %v", err)
+	}
+	release()
+	if c.Sessions() != 0 {
+		t.Fatalf("%d sessions admitted", c.Sessions())
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{Allow: []string{"10.0.0"}},
+		{Deny: []string{"10.0.0.0/33"}},
+		{NetworkLimits: map[string]int{"host": 1}},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("invalid config is accepted: %+v", config)
+		}
+	}
+}
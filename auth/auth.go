@@ -0,0 +1,52 @@
+// Package auth authenticates clients on gates. Clients are authenticated by
+// the handshake of HTTP upgraded connections or by messages of the login route,
+// the authenticated UID is bound to the session, and routes not marked by
+// component.WithAnonymousRoutes are rejected before that.
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/session"
+)
+
+// DefaultCode is the default error code responded to rejected requests
+const DefaultCode = 401
+
+// ErrUnauthenticated represents a message sent before authenticated
+var ErrUnauthenticated = errors.New("auth: unauthenticated")
+
+// Config is the config of authentication on the gate
+type Config struct {
+	// Handshake authenticates HTTP requests upgraded to client connections,
+	// such as by a token header, the request is rejected if it returns an
+	// error. A zero UID leaves the session to be authenticated by login.
+	Handshake func(r *http.Request) (uid int64, err error)
+
+	// Login authenticates messages of LoginRoute with the data of messages,
+	// the message is dispatched to the handler of LoginRoute after the UID is
+	// bound, which responds the result of login. Login is called on the read
+	// goroutine of the connection and stalls following packets of the session,
+	// so it must not block, tokens should be verified locally, such as signed
+	// tokens. Checks calling remote services should be done in the handler of
+	// an anonymous route on the gate, which binds the UID by
+	// session.Session.BindUID.
+	LoginRoute string
+	Login      func(s *session.Session, data []byte) (uid int64, err error)
+
+	// Code is the error code responded to rejected requests, DefaultCode is
+	// used if zero
+	Code int
+}
+
+// Error returns the error responded to rejected requests, errors of
+// authenticators are not exposed to clients
+func (c *Config) Error() *pipeline.Error {
+	code := c.Code
+	if code == 0 {
+		code = DefaultCode
+	}
+	return pipeline.NewError(code, "unauthenticated")
+}
@@ -0,0 +1,121 @@
+package load
+
+import (
+	"math/bits"
+	"time"
+)
+
+// subBuckets is the number of linear buckets in each power of two, which keeps
+// the relative error of recorded values below 1/16
+const subBuckets = 16
+
+// Histogram records durations in microseconds with log-linear buckets, it's not
+// safe for concurrent use
+type Histogram struct {
+	buckets [64 * subBuckets]uint64
+	count   uint64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+}
+
+func bucketOf(us uint64) int {
+	if us < subBuckets {
+		return int(us)
+	}
+	exp := bits.Len64(us) - 5 // keeps the top 5 bits
+	return exp*subBuckets + int(us>>uint(exp))
+}
+
+// lowerBound returns the lowest value of bucket in microseconds
+func lowerBound(idx int) uint64 {
+	if idx < 2*subBuckets {
+		return uint64(idx)
+	}
+	exp := idx/subBuckets - 1
+	sub := idx%subBuckets + subBuckets
+	return uint64(sub) << uint(exp)
+}
+
+// Record records a duration
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.buckets[bucketOf(uint64(d/time.Microsecond))]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Merge adds the records of other histogram
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	for i, n := range other.buckets {
+		h.buckets[i] += n
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of records
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Mean returns the average duration
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Min returns the min duration
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the max duration
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Percentile returns the duration at percentile p in [0, 100]
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(h.count))
+	if rank >= h.count {
+		return h.max
+	}
+	var seen uint64
+	for i, n := range h.buckets {
+		seen += n
+		if seen > rank {
+			d := time.Duration(lowerBound(i)) * time.Microsecond
+			if d < h.min {
+				return h.min
+			}
+			if d > h.max {
+				return h.max
+			}
+			return d
+		}
+	}
+	return h.max
+}
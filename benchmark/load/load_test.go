@@ -0,0 +1,87 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/session"
+)
+
+type Echo struct{ component.Base }
+
+func (e *Echo) Echo(s *session.Session, data []byte) error {
+	return s.Response("Echo.Echo", data)
+}
+
+func (e *Echo) Discard(s *session.Session, data []byte) error { return nil }
+
+func TestHistogram(t *testing.T) {
+	h := &Histogram{}
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	if h.Count() != 1000 || h.Min() != time.Millisecond || h.Max() != time.Second {
+		t.Fatalf("unexpected histogram: count=%d min=%v max=%v", h.Count(), h.Min(), h.Max())
+	}
+	for p, expect := range map[float64]time.Duration{50: 500 * time.Millisecond, 99: 990 * time.Millisecond} {
+		got := h.Percentile(p)
+		if diff := got - expect; diff < -expect/16 || diff > expect/16 {
+			t.Fatalf("p%v = %v, expect about %v", p, got, expect)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	const addr = "127.0.0.1:34590"
+	components := &component.Components{}
+	components.Register(&Echo{})
+	go nano.Listen(addr, nano.WithComponents(components))
+	<-nano.Ready()
+	defer nano.Shutdown()
+
+	var s Scenario
+	err := json.Unmarshal([]byte(`{
+		"addr": "`+addr+`",
+		"clients": 4,
+		"connectRate": 100,
+		"duration": "500ms",
+		"thinkTime": "5ms",
+		"serializer": "raw",
+		"requests": [
+			{"route": "Echo.Echo", "weight": 3, "payload": "client-{{.Client}}-{{.Seq}}"},
+			{"route": "Echo.Discard", "notify": true, "payload": "bye"}
+		]
+	}`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Run(context.Background(), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Connected != 4 || report.ConnectErrors != 0 {
+		t.Fatalf("unexpected clients: %d connected, %d failed", report.Connected, report.ConnectErrors)
+	}
+	if len(report.Routes) != 2 || report.Routes[1].Route != "Echo.Echo" {
+		t.Fatalf("unexpected routes: %+v", report.Routes)
+	}
+	total := report.Total()
+	if total.Requests() == 0 || total.Errors != 0 || total.Timeouts != 0 {
+		t.Fatalf("unexpected total: requests=%d errors=%d timeouts=%d", total.Requests(), total.Errors, total.Timeouts)
+	}
+
+	var buf bytes.Buffer
+	if err := report.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Echo.Echo") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,123 @@
+package load
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type (
+	// RouteStats is the statistics of a route
+	RouteStats struct {
+		Route    string
+		Latency  Histogram // latency of succeeded requests, or send time of notifies
+		Errors   uint64
+		Timeouts uint64
+	}
+
+	// Report is the result of a load
+	Report struct {
+		Clients       int           // expected clients
+		Connected     uint64        // clients connected
+		ConnectErrors uint64        // clients failed to connect
+		Elapsed       time.Duration // duration of load
+		Routes        []*RouteStats // statistics sorted by route
+		startAt       time.Time
+		mu            sync.Mutex
+		routes        map[string]*RouteStats
+	}
+)
+
+// Requests returns the number of requests sent, includes failed requests
+func (s *RouteStats) Requests() uint64 {
+	return s.Latency.Count() + s.Errors + s.Timeouts
+}
+
+// ErrorRate returns the ratio of failed requests
+func (s *RouteStats) ErrorRate() float64 {
+	if s.Requests() == 0 {
+		return 0
+	}
+	return float64(s.Errors+s.Timeouts) / float64(s.Requests())
+}
+
+func newReport(s *Scenario) *Report {
+	return &Report{
+		Clients: s.Clients,
+		startAt: time.Now(),
+		routes:  make(map[string]*RouteStats),
+	}
+}
+
+func (r *Report) connected() {
+	r.mu.Lock()
+	r.Connected++
+	r.mu.Unlock()
+}
+
+func (r *Report) connectFailed() {
+	r.mu.Lock()
+	r.ConnectErrors++
+	r.mu.Unlock()
+}
+
+// merge adds the statistics of a client
+func (r *Report) merge(stats map[string]*RouteStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for route, st := range stats {
+		total, ok := r.routes[route]
+		if !ok {
+			total = &RouteStats{Route: route}
+			r.routes[route] = total
+			r.Routes = append(r.Routes, total)
+			sort.Slice(r.Routes, func(i, j int) bool { return r.Routes[i].Route < r.Routes[j].Route })
+		}
+		total.Latency.Merge(&st.Latency)
+		total.Errors += st.Errors
+		total.Timeouts += st.Timeouts
+	}
+}
+
+// Total returns the statistics of all routes
+func (r *Report) Total() *RouteStats {
+	total := &RouteStats{Route: "TOTAL"}
+	for _, st := range r.Routes {
+		total.Latency.Merge(&st.Latency)
+		total.Errors += st.Errors
+		total.Timeouts += st.Timeouts
+	}
+	return total
+}
+
+// Throughput returns the requests per second of stats
+func (r *Report) Throughput(s *RouteStats) float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Requests()) / r.Elapsed.Seconds()
+}
+
+// Print writes the report as a table
+func (r *Report) Print(w io.Writer) error {
+	fmt.Fprintf(w, "clients: %d connected, %d failed, %d expected\n", r.Connected, r.ConnectErrors, r.Clients)
+	fmt.Fprintf(w, "elapsed: %v\n\n", r.Elapsed.Round(time.Millisecond))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "route\trequests\treq/s\terrors\ttimeouts\terror%\tmean\tp50\tp90\tp99\tmax\t")
+	for _, st := range append(r.Routes, r.Total()) {
+		h := &st.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\t%d\t%.2f\t%v\t%v\t%v\t%v\t%v\t\n",
+			st.Route, st.Requests(), r.Throughput(st), st.Errors, st.Timeouts, st.ErrorRate()*100,
+			round(h.Mean()), round(h.Percentile(50)), round(h.Percentile(90)), round(h.Percentile(99)), round(h.Max()))
+	}
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,110 @@
+package load
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aura-studio/nano/connector"
+)
+
+// Run generates the load described by scenario until the scenario duration
+// elapsed or ctx is done, and returns the report
+func Run(ctx context.Context, s *Scenario) (*Report, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.Duration))
+	defer cancel()
+
+	report := newReport(s)
+	var interval time.Duration
+	if s.ConnectRate > 0 {
+		interval = time.Duration(float64(time.Second) / s.ConnectRate)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.Clients; i++ {
+		if i > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runClient(ctx, s, i, report)
+		}(i)
+	}
+	wg.Wait()
+
+	report.Elapsed = time.Since(report.startAt)
+	return report, nil
+}
+
+// runClient connects to server and sends requests until ctx is done
+func runClient(ctx context.Context, s *Scenario, index int, report *Report) {
+	c := connector.NewConnector(
+		connector.WithWSPath(s.WSPath),
+		connector.WithRequestTimeout(time.Duration(s.Timeout)),
+	)
+	if err := c.StartWithTimeout(s.Addr, time.Duration(s.Timeout)); err != nil {
+		report.connectFailed()
+		return
+	}
+	defer c.Close()
+	report.connected()
+
+	stats := make(map[string]*RouteStats)
+	defer report.merge(stats)
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(index)))
+	for seq := 0; ctx.Err() == nil; seq++ {
+		r := s.pick(rnd)
+		st, ok := stats[r.Route]
+		if !ok {
+			st = &RouteStats{Route: r.Route}
+			stats[r.Route] = st
+		}
+
+		payload, err := s.render(r, templateData{Client: index, Seq: seq, Rand: rnd.Int63()})
+		if err != nil {
+			st.Errors++
+			return
+		}
+
+		start := time.Now()
+		if r.Notify {
+			err = c.Notify(r.Route, payload)
+		} else {
+			err = c.RequestSync(ctx, r.Route, payload, nil)
+		}
+		switch {
+		case err == nil:
+			st.Latency.Record(time.Since(start))
+		case ctx.Err() != nil:
+			// abandoned at the end of load
+			return
+		case err == connector.ErrRequestTimeout:
+			st.Timeouts++
+		default:
+			st.Errors++
+		}
+		if !c.Connected() {
+			return
+		}
+
+		if s.ThinkTime > 0 {
+			select {
+			case <-time.After(time.Duration(s.ThinkTime)):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Package load generates load against nano servers with connector.Connector,
+// the load is described by a scenario file and the latency, throughput and
+// error rates are reported per route.
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"text/template"
+	"time"
+
+	"github.com/aura-studio/nano/serialize"
+	"github.com/aura-studio/nano/serialize/cbor"
+	"github.com/aura-studio/nano/serialize/msgpack"
+)
+
+// Duration is a time.Duration decoded from JSON string, such as: "100ms"
+type Duration time.Duration
+
+// UnmarshalJSON parses duration string or nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(dur)
+	default:
+		return fmt.Errorf("load: invalid duration %s", data)
+	}
+	return nil
+}
+
+// MarshalJSON formats duration as string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+type (
+	// Scenario describes the load generated against a server
+	Scenario struct {
+		Addr        string     `json:"addr"`        // server address, TCP address or WebSocket URL
+		WSPath      string     `json:"wsPath"`      // WebSocket path
+		Clients     int        `json:"clients"`     // number of clients
+		ConnectRate float64    `json:"connectRate"` // connections per second, all at once if zero
+		Duration    Duration   `json:"duration"`    // duration of the load
+		ThinkTime   Duration   `json:"thinkTime"`   // delay between requests of a client
+		Timeout     Duration   `json:"timeout"`     // request and dial timeout
+		Serializer  string     `json:"serializer"`  // encoding of payloads: json, msgpack, cbor, raw
+		Requests    []*Request `json:"requests"`    // request mix
+	}
+
+	// Request describes a route in the request mix, payload is a JSON template
+	// rendered with fields: {{.Client}}, {{.Seq}}, {{.Rand}}
+	Request struct {
+		Route   string          `json:"route"`
+		Weight  int             `json:"weight"` // relative frequency, 1 if zero
+		Notify  bool            `json:"notify"` // sent as notify, no response expected
+		Payload json.RawMessage `json:"payload"`
+
+		tmpl *template.Template
+	}
+
+	// templateData is the data to render payload templates
+	templateData struct {
+		Client int   // client index
+		Seq    int   // request sequence of client
+		Rand   int64 // random number
+	}
+)
+
+// LoadScenario reads scenario from JSON file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scenario{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// validate checks the scenario and fills default values
+func (s *Scenario) validate() error {
+	if s.Addr == "" {
+		return fmt.Errorf("load: addr is required")
+	}
+	if s.Clients <= 0 {
+		s.Clients = 1
+	}
+	if s.Duration <= 0 {
+		s.Duration = Duration(10 * time.Second)
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = Duration(5 * time.Second)
+	}
+	if s.Serializer == "" {
+		s.Serializer = "json"
+	}
+	if _, ok := serializers[s.Serializer]; !ok && s.Serializer != "raw" {
+		return fmt.Errorf("load: unsupported serializer %s", s.Serializer)
+	}
+	if len(s.Requests) == 0 {
+		return fmt.Errorf("load: requests are required")
+	}
+	for _, r := range s.Requests {
+		if r.Route == "" {
+			return fmt.Errorf("load: route is required")
+		}
+		if r.Weight <= 0 {
+			r.Weight = 1
+		}
+		payload := string(r.Payload)
+		if s.Serializer == "raw" {
+			// raw payload is a JSON string sent as bytes
+			if err := json.Unmarshal(r.Payload, &payload); err != nil && len(r.Payload) > 0 {
+				return fmt.Errorf("load: raw payload of %s must be a string", r.Route)
+			}
+		}
+		tmpl, err := template.New(r.Route).Parse(payload)
+		if err != nil {
+			return fmt.Errorf("load: invalid payload template of %s: %v", r.Route, err)
+		}
+		r.tmpl = tmpl
+	}
+	return nil
+}
+
+// pick returns a request of mix by weights
+func (s *Scenario) pick(rnd *rand.Rand) *Request {
+	total := 0
+	for _, r := range s.Requests {
+		total += r.Weight
+	}
+	n := rnd.Intn(total)
+	for _, r := range s.Requests {
+		if n < r.Weight {
+			return r
+		}
+		n -= r.Weight
+	}
+	return s.Requests[len(s.Requests)-1]
+}
+
+var serializers = map[string]serialize.Serializer{
+	"msgpack": msgpack.NewSerializer(),
+	"cbor":    cbor.NewSerializer(),
+}
+
+// render renders the payload template and encodes it by serializer
+func (s *Scenario) render(r *Request, data templateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	switch s.Serializer {
+	case "json", "raw":
+		return buf.Bytes(), nil
+	default:
+		var v interface{}
+		if buf.Len() > 0 {
+			if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+				return nil, err
+			}
+		}
+		return serializers[s.Serializer].Marshal(v)
+	}
+}
@@ -3,71 +3,115 @@ package cluster
 import (
 	"context"
 	"net"
+	"sync"
 
 	"github.com/aura-studio/nano/cluster/clusterpb"
-	"github.com/aura-studio/nano/env"
-	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/message"
-	"github.com/aura-studio/nano/serialize"
+	"github.com/aura-studio/nano/metrics"
 	"github.com/aura-studio/nano/session"
+	"github.com/aura-studio/nano/tracing"
 )
 
 type acceptor struct {
-	sid         int64
-	gateClient  clusterpb.MemberClient
-	session     *session.Session
-	lastMid     uint64
-	rpcHandler  rpcHandler
-	gateAddr    string
-	serializers map[string]serialize.Serializer // copy system serializers for agent
-	remoteAddr  net.Addr
+	sid        int64
+	gateClient clusterpb.MemberClient
+	session    *session.Session
+	lastMid    uint64
+	rpcHandler rpcHandler
+	gateAddr   string
+	remoteAddr net.Addr
+
+	muTrace sync.Mutex
+	calls   []handlerCall // handlers running, which link pushes and responses
+}
+
+// handlerCall is the span of a running handler of message mid
+type handlerCall struct {
+	mid  uint64
+	span tracing.SpanContext
+}
+
+// traceCall links pushes and responses sent by the handler of message mid to
+// the span, until the returned func is called
+func (a *acceptor) traceCall(mid uint64, span tracing.SpanContext) func() {
+	a.muTrace.Lock()
+	defer a.muTrace.Unlock()
+
+	call := handlerCall{mid: mid, span: span}
+	a.calls = append(a.calls, call)
+	return func() {
+		a.muTrace.Lock()
+		defer a.muTrace.Unlock()
+
+		for i := range a.calls {
+			if a.calls[i] == call {
+				a.calls = append(a.calls[:i], a.calls[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// traceOf returns the span linking a response of message mid, or a push if
+// response is false. Pushes are only linked while a single handler is running,
+// since they cannot be told apart by handlers.
+func (a *acceptor) traceOf(mid uint64, response bool) tracing.SpanContext {
+	a.muTrace.Lock()
+	defer a.muTrace.Unlock()
+
+	if !response {
+		if len(a.calls) == 1 {
+			return a.calls[0].span
+		}
+		return tracing.SpanContext{}
+	}
+	for _, call := range a.calls {
+		if call.mid == mid {
+			return call.span
+		}
+	}
+	return tracing.SpanContext{}
 }
 
 // Push implements the session.NetworkEntity interface
 func (a *acceptor) Push(route string, v interface{}) error {
-	data, err := message.Serialize(v)
+	return a.PushPriority(route, v, message.PriorityDefault)
+}
+
+// PushPriority implements the session.PriorityPusher interface, the priority
+// is resolved by the gate if p is message.PriorityDefault
+func (a *acceptor) PushPriority(route string, v interface{}, p message.Priority) error {
+	data, err := message.SerializeRoute(route, v)
 	if err != nil {
 		return err
 	}
 
-	if env.Debug {
-		switch d := v.(type) {
-		case []byte:
-			log.Infof("Type=Push, Route=%s, ID=%d, Version=%s, UID=%d, MID=%d, Data=%dbytes",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), 0, len(d))
-		default:
-			log.Infof("Type=Push, Route=%s, ID=%d, Version=%s, UID=%d, Mid=%d, Data=%+v",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), 0, v)
-		}
-	}
+	debugMessage(a.session, message.Push, route, 0, v)
 
+	ctx, traceParent := withTrace(context.Background(), a.traceOf(0, false))
 	request := &clusterpb.PushMessage{
-		SessionID: a.sid,
-		ShortVer:  a.session.ShortVer(),
-		Route:     route,
-		Data:      data,
+		SessionID:   a.sid,
+		ShortVer:    a.session.ShortVer(),
+		Route:       route,
+		Data:        data,
+		Priority:    uint32(p),
+		TraceParent: traceParent,
+	}
+	_, err = a.gateClient.HandlePush(ctx, request)
+	if err == nil {
+		metrics.Messages.Inc(metrics.Out, message.Push.String(), route)
 	}
-	_, err = a.gateClient.HandlePush(context.Background(), request)
 	return err
 }
 
 // RPC implements the session.NetworkEntity interface
 func (a *acceptor) RPC(route string, v interface{}) error {
-	data, err := message.RouteSerialize(a.serializers, route, v)
+	data, err := message.SerializeRoute(route, v)
 	if err != nil {
 		return err
 	}
 
-	if env.Debug {
-		switch d := v.(type) {
-		case []byte:
-			log.Infof("Type=Notify, Route=%s, ID=%d, Version=%s, UID=%d,  MID=%d, Data=%dbytes",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), a.lastMid, len(d))
-		default:
-			log.Infof("Type=Notify, Route=%s, ID=%d, Version=%s, UID=%d,  Mid=%d, Data=%+v",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), a.lastMid, v)
-		}
-	}
+	debugMessage(a.session, message.Notify, route, a.lastMid, v)
 
 	msg := &message.Message{
 		Type:     message.Notify,
@@ -93,30 +137,27 @@ func (a *acceptor) Response(route string, v interface{}) error {
 
 // ResponseMid implements the session.NetworkEntity interface
 func (a *acceptor) ResponseMid(mid uint64, route string, v interface{}) error {
-	data, err := message.Serialize(v)
+	data, isError, err := serializePayload(route, v)
 	if err != nil {
 		return err
 	}
 
-	if env.Debug {
-		switch d := v.(type) {
-		case []byte:
-			log.Infof("Type=Response, Route=%s, ID=%d, Version=%s, UID=%d,  MID=%d, Data=%dbytes",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), mid, len(d))
-		default:
-			log.Infof("Type=Response, Route=%s, ID=%d, Version=%s, UID=%d,  Mid=%d, Data=%+v",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), mid, v)
-		}
-	}
+	debugMessage(a.session, message.Response, route, mid, v)
 
+	ctx, traceParent := withTrace(context.Background(), a.traceOf(mid, true))
 	request := &clusterpb.ResponseMessage{
-		SessionID: a.sid,
-		ShortVer:  a.session.ShortVer(),
-		ID:        mid,
-		Route:     route,
-		Data:      data,
+		SessionID:   a.sid,
+		ShortVer:    a.session.ShortVer(),
+		ID:          mid,
+		Route:       route,
+		Data:        data,
+		TraceParent: traceParent,
+		Error:       isError,
+	}
+	_, err = a.gateClient.HandleResponse(ctx, request)
+	if err == nil {
+		metrics.Messages.Inc(metrics.Out, message.Response.String(), route)
 	}
-	_, err = a.gateClient.HandleResponse(context.Background(), request)
 	return err
 }
 
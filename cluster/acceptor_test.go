@@ -0,0 +1,36 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/aura-studio/nano/tracing"
+)
+
+func TestAcceptorTrace(t *testing.T) {
+	a := &acceptor{}
+	first := tracing.SpanContext{TraceID: tracing.TraceID{1}, SpanID: tracing.SpanID{1}, Sampled: true}
+	second := tracing.SpanContext{TraceID: tracing.TraceID{2}, SpanID: tracing.SpanID{2}, Sampled: true}
+
+	endFirst := a.traceCall(1, first)
+	if a.traceOf(1, true) != first || a.traceOf(0, false) != first {
+		t.Fatal("pushes and responses are not linked to the running handler")
+	}
+
+	// pushes are not linked while handlers run concurrently
+	endSecond := a.traceCall(2, second)
+	if a.traceOf(1, true) != first || a.traceOf(2, true) != second {
+		t.Fatal("responses are not linked by message ids")
+	}
+	if a.traceOf(0, false).IsValid() {
+		t.Fatal("push is linked to one of concurrent handlers")
+	}
+
+	endFirst()
+	if a.traceOf(1, true).IsValid() || a.traceOf(0, false) != second {
+		t.Fatal("span is linked after the handler returns")
+	}
+	endSecond()
+	if a.traceOf(0, false).IsValid() {
+		t.Fatal("span is linked without handlers")
+	}
+}
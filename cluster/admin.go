@@ -0,0 +1,269 @@
+package cluster
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/aura-studio/nano/cluster/clusterpb"
+	"github.com/aura-studio/nano/env"
+	"github.com/aura-studio/nano/log"
+	"github.com/aura-studio/nano/scheduler"
+	"github.com/gorilla/mux"
+)
+
+var (
+	muAdminResources sync.RWMutex
+	adminResources   = map[string]func() interface{}{}
+)
+
+// RegisterAdminResource registers a read-only resource of the admin API, the
+// value returned by fn is served as JSON on "/admin/{name}"
+func RegisterAdminResource(name string, fn func() interface{}) {
+	muAdminResources.Lock()
+	defer muAdminResources.Unlock()
+	adminResources[name] = fn
+}
+
+type (
+	adminMember struct {
+		Label       string   `json:"label"`
+		ServiceAddr string   `json:"serviceAddr"`
+		Version     string   `json:"version"`
+		Services    []string `json:"services"`
+		IsMaster    bool     `json:"isMaster"`
+		Draining    bool     `json:"draining"`
+		Self        bool     `json:"self"`
+	}
+
+	adminRoute struct {
+		Route      string `json:"route"`
+		Code       uint32 `json:"code"`
+		Type       string `json:"type,omitempty"`
+		Serializer uint32 `json:"serializer"`
+	}
+
+	adminRoutes struct {
+		Local  []adminRoute            `json:"local"`
+		Remote map[string][]adminRoute `json:"remote"` // service address to routes
+	}
+
+	adminSession struct {
+		ID         int64             `json:"id"`
+		UID        int64             `json:"uid"`
+		Version    string            `json:"version"`
+		RemoteAddr string            `json:"remoteAddr"`
+		GateAddr   string            `json:"gateAddr,omitempty"` // empty for sessions of clients
+		Bindings   map[string]string `json:"bindings"`
+	}
+)
+
+// AdminHandler returns the handler of admin API, which is served on "/admin/"
+// of the debug address, read-only resources are always served:
+//
+//	GET  /admin/members               members of cluster
+//	GET  /admin/routes                local and remote route dictionary
+//	GET  /admin/sessions              active sessions
+//	GET  /admin/versions              version policies
+//	GET  /admin/timers                timers of scheduler
+//	GET  /admin/{name}                resources registered by RegisterAdminResource
+//
+// and operations are only served if Options.AdminAuth is set, requests not
+// authorized by it are rejected with 401:
+//
+//	POST /admin/members/{addr}/drain  stop routing new sessions to member
+//	POST /admin/sessions/{id}/kick    close session
+//	PUT  /admin/versions              replace version policies of this node only
+func (n *Node) AdminHandler() http.Handler {
+	router := mux.NewRouter()
+	r := router.PathPrefix("/admin").Subrouter()
+	r.HandleFunc("/members", n.adminMembers).Methods(http.MethodGet)
+	r.HandleFunc("/routes", n.adminRoutes).Methods(http.MethodGet)
+	r.HandleFunc("/sessions", n.adminSessions).Methods(http.MethodGet)
+	r.HandleFunc("/versions", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, n.handler.versionPolicies())
+	}).Methods(http.MethodGet)
+	r.HandleFunc("/timers", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, scheduler.Timers())
+	}).Methods(http.MethodGet)
+
+	if n.AdminAuth != nil {
+		r.HandleFunc("/members/{addr}/drain", n.adminAuthorize(n.adminDrain)).Methods(http.MethodPost)
+		r.HandleFunc("/sessions/{id:[0-9]+}/kick", n.adminAuthorize(n.adminKick)).Methods(http.MethodPost)
+		r.HandleFunc("/versions", n.adminAuthorize(n.adminSetVersions)).Methods(http.MethodPut)
+	}
+
+	r.HandleFunc("/{name}", adminResource).Methods(http.MethodGet)
+	return router
+}
+
+// AdminBearerToken returns an authorization func of the admin API, which
+// accepts requests with the header "Authorization: Bearer {token}"
+func AdminBearerToken(token string) func(r *http.Request) bool {
+	expect := []byte("Bearer " + token)
+	return func(r *http.Request) bool {
+		return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expect) == 1
+	}
+}
+
+// adminAuthorize rejects requests not authorized by AdminAuth
+func (n *Node) adminAuthorize(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !n.AdminAuth(r) {
+			writeError(w, http.StatusUnauthorized, ErrAdminUnauthorized)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("nano/admin: write response error: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func (n *Node) adminMembers(w http.ResponseWriter, _ *http.Request) {
+	var members []adminMember
+	self := false
+	for _, m := range n.cluster.memberList() {
+		info := m.MemberInfo()
+		member := adminMember{
+			Label:       info.Label,
+			ServiceAddr: info.ServiceAddr,
+			Version:     info.Version,
+			Services:    info.Services,
+			IsMaster:    m.isMaster || info.ServiceAddr == n.AdvertiseAddr,
+			Draining:    info.Draining || n.handler.isDraining(info.ServiceAddr),
+			Self:        info.ServiceAddr == n.ServiceAddr,
+		}
+		self = self || member.Self
+		members = append(members, member)
+	}
+
+	// members except master don't receive their own info
+	if !self {
+		members = append(members, adminMember{
+			Label:       n.Label,
+			ServiceAddr: n.ServiceAddr,
+			Version:     env.Version,
+			Services:    n.handler.LocalService(),
+			IsMaster:    n.IsMaster,
+			Draining:    n.handler.isDraining(n.ServiceAddr),
+			Self:        true,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ServiceAddr < members[j].ServiceAddr })
+	writeJSON(w, http.StatusOK, members)
+}
+
+func (n *Node) adminDrain(w http.ResponseWriter, r *http.Request) {
+	if err := n.Drain(mux.Vars(r)["addr"]); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toAdminRoutes(items []*clusterpb.DictionaryItem) []adminRoute {
+	routes := make([]adminRoute, 0, len(items))
+	for _, item := range items {
+		routes = append(routes, adminRoute{
+			Route:      item.Route,
+			Code:       item.Code,
+			Type:       item.Type,
+			Serializer: item.Serializer,
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	return routes
+}
+
+func (n *Node) adminRoutes(w http.ResponseWriter, _ *http.Request) {
+	routes := adminRoutes{
+		Local:  toAdminRoutes(n.handler.LocalDictionary()),
+		Remote: map[string][]adminRoute{},
+	}
+
+	n.handler.mu.RLock()
+	for _, versions := range n.handler.remoteServices {
+		for _, members := range versions {
+			for _, m := range members {
+				routes.Remote[m.ServiceAddr] = toAdminRoutes(m.Dictionary)
+			}
+		}
+	}
+	n.handler.mu.RUnlock()
+	writeJSON(w, http.StatusOK, routes)
+}
+
+func (n *Node) adminSessions(w http.ResponseWriter, _ *http.Request) {
+	n.mu.RLock()
+	sessions := make([]adminSession, 0, len(n.sessions))
+	for _, s := range n.sessions {
+		session := adminSession{
+			ID:         s.ID(),
+			UID:        s.UID(),
+			Version:    s.Version(),
+			RemoteAddr: s.RemoteAddr().String(),
+			Bindings:   s.Router().Bindings(),
+		}
+		if a, ok := s.NetworkEntity().(*acceptor); ok {
+			session.GateAddr = a.gateAddr
+		}
+		sessions = append(sessions, session)
+	}
+	n.mu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (n *Node) adminKick(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s := n.findSession(id)
+	if s == nil {
+		writeError(w, http.StatusNotFound, ErrSessionNotFound)
+		return
+	}
+	s.Close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (n *Node) adminSetVersions(w http.ResponseWriter, r *http.Request) {
+	var policies []VersionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policies); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := n.SetVersionPolicies(policies); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, n.handler.versionPolicies())
+}
+
+func adminResource(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	muAdminResources.RLock()
+	fn, ok := adminResources[name]
+	muAdminResources.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrAdminResourceNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, fn())
+}
@@ -0,0 +1,185 @@
+package cluster_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano"
+	"github.com/aura-studio/nano/benchmark/testdata"
+	"github.com/aura-studio/nano/cluster"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/nanotest"
+	"github.com/aura-studio/nano/session"
+)
+
+type RoomComponent struct {
+	component.Base
+	addr string
+}
+
+func (c *RoomComponent) Join(s *session.Session, ping *testdata.Ping) error {
+	return s.Response("RoomComponent.Join", &testdata.Pong{Content: c.addr})
+}
+
+func getJSON(t *testing.T, server *httptest.Server, path string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s responds %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const adminToken = "Bearer secret"
+
+func post(t *testing.T, server *httptest.Server, path string) int {
+	t.Helper()
+	return do(t, http.MethodPost, server.URL+path, "", adminToken)
+}
+
+func do(t *testing.T, method, url, body, token string) int {
+	t.Helper()
+	r, _ := http.NewRequest(method, url, strings.NewReader(body))
+	r.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAdmin(t *testing.T) {
+	c := nanotest.NewCluster(t)
+	defer c.Close()
+
+	c.AddMaster()
+	gate := c.AddNode(nano.WithAdminAuth(cluster.AdminBearerToken("secret")))
+	for _, addr := range []string{"node-2", "node-3"} {
+		comps := &component.Components{}
+		comps.Register(&RoomComponent{addr: addr})
+		c.AddNode(nano.WithComponents(comps))
+	}
+
+	server := httptest.NewServer(gate.AdminHandler())
+	defer server.Close()
+
+	var members []struct {
+		ServiceAddr string
+		IsMaster    bool
+		Draining    bool
+		Self        bool
+	}
+	getJSON(t, server, "/admin/members", &members)
+	if len(members) != 4 || members[0].ServiceAddr != "master" || !members[0].IsMaster || !members[1].Self {
+		t.Fatalf("unexpected members: %+v", members)
+	}
+
+	client := gate.Connect()
+	defer client.Close()
+	disconnected := make(chan struct{})
+	client.OnDisconnected(func(interface{}) { close(disconnected) })
+
+	pong := &testdata.Pong{}
+	client.MustCall("RoomComponent.Join", &testdata.Ping{}, pong)
+
+	var sessions []struct{ ID int64 }
+	getJSON(t, server, "/admin/sessions", &sessions)
+	if len(sessions) != 1 {
+		t.Fatalf("unexpected sessions: %+v", sessions)
+	}
+
+	var routes struct {
+		Remote map[string][]struct{ Route string }
+	}
+	getJSON(t, server, "/admin/routes", &routes)
+	if r := routes.Remote[pong.Content]; len(r) != 1 || r[0].Route != "RoomComponent.Join" {
+		t.Fatalf("unexpected routes: %+v", routes)
+	}
+
+	// operations are authorized
+	kick := server.URL + "/admin/sessions/" + strconv.FormatInt(sessions[0].ID, 10) + "/kick"
+	if code := do(t, http.MethodPost, kick, "", "Bearer guess"); code != http.StatusUnauthorized {
+		t.Fatalf("unauthorized kick responds %d", code)
+	}
+	if code := post(t, server, "/admin/sessions/"+strconv.FormatInt(sessions[0].ID, 10)+"/kick"); code != http.StatusNoContent {
+		t.Fatalf("kick responds %d", code)
+	}
+	select {
+	case <-disconnected:
+	case <-time.After(nanotest.DefaultTimeout):
+		t.Fatal("kicked client is not disconnected")
+	}
+	if code := post(t, server, "/admin/sessions/0/kick"); code != http.StatusNotFound {
+		t.Fatalf("kick unknown session responds %d", code)
+	}
+
+	if code := post(t, server, "/admin/members/node-2/drain"); code != http.StatusNoContent {
+		t.Fatalf("drain responds %d", code)
+	}
+	deadline := time.Now().Add(nanotest.DefaultTimeout)
+	for {
+		getJSON(t, server, "/admin/members", &members)
+		if members[2].ServiceAddr == "node-2" && members[2].Draining {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("member is not draining: %+v", members)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i := 0; i < 4; i++ {
+		client := gate.Connect()
+		client.MustCall("RoomComponent.Join", &testdata.Ping{}, pong)
+		client.Close()
+		if pong.Content != "node-3" {
+			t.Fatalf("session routed to draining member %s", pong.Content)
+		}
+	}
+
+	var groups []interface{}
+	getJSON(t, server, "/admin/groups", &groups)
+
+	for body, code := range map[string]int{
+		`[{"version":"v2-2","percent":10,"uids":[1]}]`: http.StatusOK,
+		`[{"version":"v2-2","percent":110}]`:           http.StatusBadRequest,
+	} {
+		if got := do(t, http.MethodPut, server.URL+"/admin/versions", body, adminToken); got != code {
+			t.Fatalf("PUT %s responds %d", body, got)
+		}
+	}
+	var policies []cluster.VersionPolicy
+	getJSON(t, server, "/admin/versions", &policies)
+	if len(policies) != 1 || policies[0].Percent != 10 {
+		t.Fatalf("unexpected policies: %+v", policies)
+	}
+}
+
+func TestAdminReadOnly(t *testing.T) {
+	server := nanotest.NewServer(t)
+	defer server.Close()
+
+	admin := httptest.NewServer(server.AdminHandler())
+	defer admin.Close()
+
+	var members []interface{}
+	getJSON(t, admin, "/admin/members", &members)
+	if code := post(t, admin, "/admin/members/node-2/drain"); code != http.StatusNotFound {
+		t.Fatalf("drain without admin auth responds %d", code)
+	}
+	if code := do(t, http.MethodPut, admin.URL+"/admin/versions", "[]", adminToken); code != http.StatusMethodNotAllowed {
+		t.Fatalf("PUT versions without admin auth responds %d", code)
+	}
+}
@@ -29,11 +29,11 @@ import (
 	"time"
 
 	"github.com/aura-studio/nano/codec"
-	"github.com/aura-studio/nano/env"
 	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/metrics"
 	"github.com/aura-studio/nano/pipeline"
-	"github.com/aura-studio/nano/serialize"
+	"github.com/aura-studio/nano/replay"
 	"github.com/aura-studio/nano/service"
 	"github.com/aura-studio/nano/session"
 )
@@ -54,50 +54,53 @@ type (
 	// Agent corresponding a user, used for store raw conn information
 	agent struct {
 		// regular agent member
-		session  *session.Session    // session
-		conn     net.Conn            // low-level conn fd
-		lastMid  uint64              // last message id
-		state    int32               // current agent state
-		chDie    chan struct{}       // wait for close
-		chSend   chan pendingMessage // push message queue
-		lastAt   int64               // last heartbeat unix time stamp
-		decoder  *codec.Decoder      // binary decoder
+		session  *session.Session                           // session
+		conn     net.Conn                                   // low-level conn fd
+		lastMid  uint64                                     // last message id
+		state    int32                                      // current agent state
+		chDie    chan struct{}                              // wait for close
+		chSend   [message.PriorityLanes]chan pendingMessage // push message queues, one lane per priority
+		lastAt   int64                                      // last heartbeat unix time stamp
+		decoder  *codec.Decoder                             // binary decoder
 		pipeline pipeline.Pipeline
 
-		rpcHandler  rpcHandler
-		srv         reflect.Value                   // cached session reflect.Value
-		routes      map[string]uint16               // copy system routes for agent
-		codes       map[uint16]string               // copy system codes for agent
-		serializers map[string]serialize.Serializer // copy system serializers for agent
-		compressed  bool                            // whether to use compressed msg to client
-		recvPckCnt  int64                           // agent receive packet count
-		sendPckCnt  int64                           // agent send packet count
+		rpcHandler rpcHandler
+		srv        reflect.Value     // cached session reflect.Value
+		routes     map[string]uint16 // copy system routes for agent
+		codes      map[uint16]string // copy system codes for agent
+		compressed bool              // whether to use compressed msg to client
+		recvPckCnt int64             // agent receive packet count
+		sendPckCnt int64             // agent send packet count
+		window     *replay.Window    // request ids received, created lazily
+		nodeDie    <-chan struct{}   // closed when the node shuts down
+		accepted   bool              // whether a valid message is received
 	}
 
 	pendingMessage struct {
-		typ     message.Type // message type
-		route   string       // message route(push)
-		mid     uint64       // response message id(response)
-		payload interface{}  // payload
+		typ     message.Type     // message type
+		route   string           // message route(push)
+		mid     uint64           // response message id(response)
+		payload interface{}      // payload
+		prio    message.Priority // message priority
 	}
 )
 
 // Create new agent instance
 func newAgent(conn net.Conn, pipeline pipeline.Pipeline, rpcHandler rpcHandler) *agent {
 	routes, codes := message.ReadDictionary()
-	serializers := message.ReadSerializers()
 	a := &agent{
-		conn:        conn,
-		state:       statusStart,
-		chDie:       make(chan struct{}),
-		lastAt:      time.Now().Unix(),
-		chSend:      make(chan pendingMessage, agentWriteBacklog),
-		decoder:     codec.NewDecoder(),
-		pipeline:    pipeline,
-		rpcHandler:  rpcHandler,
-		routes:      routes,
-		codes:       codes,
-		serializers: serializers,
+		conn:       conn,
+		state:      statusStart,
+		chDie:      make(chan struct{}),
+		lastAt:     time.Now().Unix(),
+		decoder:    codec.NewDecoder(),
+		pipeline:   pipeline,
+		rpcHandler: rpcHandler,
+		routes:     routes,
+		codes:      codes,
+	}
+	for i := range a.chSend {
+		a.chSend[i] = make(chan pendingMessage, agentWriteBacklog)
 	}
 
 	// binding session
@@ -115,7 +118,8 @@ func (a *agent) send(m pendingMessage) (err error) {
 			err = ErrBrokenPipe
 		}
 	}()
-	a.chSend <- m
+	a.chSend[m.prio.Lane()] <- m
+	metrics.SendQueueDepth.Add(1, m.prio.String())
 	return
 }
 
@@ -126,26 +130,23 @@ func (a *agent) LastMid() uint64 {
 
 // Push, implementation for session.NetworkEntity interface
 func (a *agent) Push(route string, v interface{}) error {
+	return a.PushPriority(route, v, message.PriorityDefault)
+}
+
+// PushPriority, implementation for session.PriorityPusher interface
+func (a *agent) PushPriority(route string, v interface{}, p message.Priority) error {
 	if a.status() == statusClosed {
 		return ErrBrokenPipe
 	}
 
-	if len(a.chSend) >= agentWriteBacklog {
+	p = message.RoutePriority(route, p)
+	if len(a.chSend[p.Lane()]) >= agentWriteBacklog {
 		return ErrBufferExceed
 	}
 
-	if env.Debug {
-		switch d := v.(type) {
-		case []byte:
-			log.Infof("Type=Push, Route=%s, ID=%d, Version=%s, UID=%d,  MID=%d, Data=%dbytes",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), 0, len(d))
-		default:
-			log.Infof("Type=Push, Route=%s, ID=%d, Version=%s, UID=%d, MID=%d, Data=%+v",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), 0, v)
-		}
-	}
+	debugMessage(a.session, message.Push, route, 0, v)
 
-	return a.send(pendingMessage{typ: message.Push, route: route, payload: v})
+	return a.send(pendingMessage{typ: message.Push, route: route, payload: v, prio: p})
 }
 
 // RPC, implementation for session.NetworkEntity interface
@@ -154,21 +155,12 @@ func (a *agent) RPC(route string, v interface{}) error {
 		return ErrBrokenPipe
 	}
 
-	data, err := message.RouteSerialize(a.serializers, route, v)
+	data, err := message.SerializeRoute(route, v)
 	if err != nil {
 		return err
 	}
 
-	if env.Debug {
-		switch d := v.(type) {
-		case []byte:
-			log.Infof("Type=Notify, Route=%s, ID=%d, Version=%s, UID=%d, MID=%d, Data=%dbytes",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), a.lastMid, len(d))
-		default:
-			log.Infof("Type=Notify, Route=%s, ID=%d, Version=%s, UID=%d, MID=%d, Data=%+v",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), a.lastMid, v)
-		}
-	}
+	debugMessage(a.session, message.Notify, route, a.lastMid, v)
 
 	msg := &message.Message{
 		Type:     message.Notify,
@@ -194,22 +186,13 @@ func (a *agent) ResponseMid(mid uint64, route string, v interface{}) error {
 		return ErrBrokenPipe
 	}
 
-	if len(a.chSend) >= agentWriteBacklog {
+	if len(a.chSend[message.PriorityResponse.Lane()]) >= agentWriteBacklog {
 		return ErrBufferExceed
 	}
 
-	if env.Debug {
-		switch d := v.(type) {
-		case []byte:
-			log.Infof("Type=Response, Route=%s, ID=%d, Version=%s, UID=%d, MID=%d, Data=%dbytes",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), mid, len(d))
-		default:
-			log.Infof("Type=Response, Route=%s, ID=%d, Version=%s, UID=%d, MID=%d, Data=%+v",
-				route, a.session.ID(), a.session.Version(), a.session.UID(), mid, v)
-		}
-	}
+	debugMessage(a.session, message.Response, route, mid, v)
 
-	return a.send(pendingMessage{typ: message.Response, route: route, mid: mid, payload: v})
+	return a.send(pendingMessage{typ: message.Response, route: route, mid: mid, payload: v, prio: message.PriorityResponse})
 }
 
 // Close, implementation for session.NetworkEntity interface
@@ -221,10 +204,7 @@ func (a *agent) Close() error {
 	}
 	a.setStatus(statusClosed)
 
-	if env.Debug {
-		log.Infof("Session closed, ID=%d, UID=%d, IP=%s",
-			a.session.ID(), a.session.UID(), a.conn.RemoteAddr())
-	}
+	debugSession(a.session, "session closed", "remote", a.conn.RemoteAddr())
 
 	// prevent closing closed channel
 	select {
@@ -258,83 +238,135 @@ func (a *agent) setStatus(state int32) {
 	atomic.StoreInt32(&a.state, state)
 }
 
+// pending returns the head of the highest non-empty lane without blocking
+func (a *agent) pending() (pendingMessage, bool) {
+	for i := len(a.chSend) - 1; i >= 0; i-- {
+		select {
+		case data := <-a.chSend[i]:
+			return data, true
+		default:
+		}
+	}
+	return pendingMessage{}, false
+}
+
 func (a *agent) write() {
-	chWrite := make(chan []byte, agentWriteBacklog)
 	// clean func
 	defer func() {
-		close(a.chSend)
-		close(chWrite)
-		a.Close()
-		if env.Debug {
-			log.Infof("Session write goroutine exit, SessionID=%d, UID=%d",
-				a.session.ID(), a.session.UID())
+		for i := range a.chSend {
+			close(a.chSend[i])
+			// messages left in the lane are discarded
+			metrics.SendQueueDepth.Add(-float64(len(a.chSend[i])), message.Priority(i+1).String())
 		}
+		a.Close()
+		debugSession(a.session, "session write goroutine exit")
 	}()
 
 	for {
-		select {
-		case data := <-chWrite:
-			// close agent while low-level conn broken
-			if _, err := a.conn.Write(data); err != nil {
-				log.Errorln(err.Error())
+		data, ok := a.pending()
+		if !ok {
+			// all lanes are empty, wait for the next message from any lane
+			select {
+			case data = <-a.chSend[message.PriorityResponse.Lane()]:
+			case data = <-a.chSend[message.PriorityCritical.Lane()]:
+			case data = <-a.chSend[message.PriorityNormal.Lane()]:
+			case data = <-a.chSend[message.PriorityBulk.Lane()]:
+
+			case <-a.chDie: // agent closed signal
 				return
-			}
-
-		case data := <-a.chSend:
-			payload, err := message.Serialize(data.payload)
-			if err != nil {
-				switch data.typ {
-				case message.Push:
-					log.Errorf("Push: %s error: %s", data.route, err.Error())
-				case message.Response:
-					log.Errorf("Response message(id: %d) error: %s", data.mid, err.Error())
-				default:
-					// expect
-				}
-				break
-			}
 
-			// construct message and encode
-			m := &message.Message{
-				Type:     data.typ,
-				ShortVer: a.session.ShortVer(),
-				Data:     payload,
-				Route:    data.route,
-				ID:       data.mid,
-			}
-			if pipe := a.pipeline; pipe != nil {
-				err := pipe.Outbound().Process(a.session, m)
-				if err != nil {
-					log.Errorln("broken pipeline", err.Error())
-					break
-				}
+			case <-a.nodeDie: // node shut down, after drained if draining
+				return
 			}
+		}
 
-			var routes map[string]uint16
-			if a.compressed {
-				routes = a.routes
-			}
-			em, err := message.Encode(m, routes)
-			if err != nil {
-				log.Errorln(err.Error())
-				break
-			}
+		metrics.SendQueueDepth.Add(-1, data.prio.String())
+		p, err := a.encode(data)
+		if err != nil {
+			continue
+		}
 
-			// packet encode
-			p, err := codec.Encode(em)
-			if err != nil {
-				log.Errorln(err)
-				break
-			}
+		// close agent while low-level conn broken
+		if _, err := a.conn.Write(p); err != nil {
+			log.Errorln(err.Error())
+			return
+		}
+	}
+}
 
-			a.sendPckCnt++
-			chWrite <- p
+// serializePayload serializes the payload by the serializer of route, errors
+// of pipeline are encoded by themselves, so that clients can decode them
+// without knowing the response type
+func serializePayload(route string, v interface{}) ([]byte, bool, error) {
+	if e, ok := v.(*pipeline.Error); ok {
+		return e.Encode(), true, nil
+	}
+	data, err := message.SerializeRoute(route, v)
+	return data, false, err
+}
 
-		case <-a.chDie: // agent closed signal
-			return
+// encode serializes the pending message and encodes it to network bytes
+func (a *agent) encode(data pendingMessage) ([]byte, error) {
+	payload, isError, err := serializePayload(data.route, data.payload)
+	if err != nil {
+		switch data.typ {
+		case message.Push:
+			log.Errorf("Push: %s error: %s", data.route, err.Error())
+		case message.Response:
+			log.Errorf("Response message(id: %d) error: %s", data.mid, err.Error())
+		default:
+			// expect
+		}
+		return nil, err
+	}
 
-		case <-env.Die: // application quit
-			return
+	// construct message and encode
+	m := &message.Message{
+		Type:     data.typ,
+		ShortVer: a.session.ShortVer(),
+		Data:     payload,
+		Route:    data.route,
+		ID:       data.mid,
+		Error:    isError,
+	}
+	if pipe := a.pipeline; pipe != nil {
+		passed := false
+		err := pipe.Outbound().Handle(a.session, m, func(*session.Session, *message.Message) error {
+			passed = true
+			return nil
+		})
+		if err != nil {
+			log.Errorln("broken pipeline", err.Error())
+			return nil, err
 		}
+		if !passed {
+			return nil, pipeline.ErrDropped
+		}
+	}
+
+	var routes map[string]uint16
+	if a.compressed {
+		routes = a.routes
+	}
+	em, err := message.Encode(m, routes)
+	if err != nil {
+		log.Errorln(err.Error())
+		return nil, err
+	}
+
+	// packet encode
+	p, err := codec.Encode(em)
+	if err != nil {
+		log.Errorln(err)
+		return nil, err
+	}
+
+	a.sendPckCnt++
+	route := data.route
+	if data.typ == message.Response {
+		// responses of rejected requests carry routes from clients
+		route = routeLabel(route)
 	}
+	metrics.Messages.Inc(metrics.Out, data.typ.String(), route)
+	return p, nil
 }
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano/codec"
+	"github.com/aura-studio/nano/message"
+)
+
+func TestAgentPriorityLanes(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	a := newAgent(server, nil, nil)
+	message.WritePriorityItem("bulk", message.PriorityBulk)
+	defer message.WritePriorityItem("bulk", message.PriorityDefault)
+
+	// queue messages before the writer starts so that all lanes are filled
+	for i := 0; i < 3; i++ {
+		if err := a.Push("bulk", []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Push("normal", []byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.PushPriority("critical", []byte{0}, message.PriorityCritical); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ResponseMid(1, "", []byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	go a.write()
+	defer a.Close()
+
+	expects := []struct {
+		route string
+		data  byte
+	}{
+		{"", 0},
+		{"critical", 0},
+		{"normal", 0},
+		{"bulk", 0},
+		{"bulk", 1},
+		{"bulk", 2},
+	}
+
+	decoder := codec.NewDecoder()
+	buf := make([]byte, 2048)
+	var msgs []*message.Message
+	for len(msgs) < len(expects) {
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets, err := decoder.Decode(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range packets {
+			// packet data is only valid until the next decode
+			data := append([]byte(nil), p.Data...)
+			m, _, err := message.Decode(data, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msgs = append(msgs, m)
+		}
+	}
+
+	for i, e := range expects {
+		if msgs[i].Route != e.route || msgs[i].Data[0] != e.data {
+			t.Fatalf("message %d: expect %s(%d), got %s(%d)", i, e.route, e.data, msgs[i].Route, msgs[i].Data[0])
+		}
+	}
+}
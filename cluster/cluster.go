@@ -23,10 +23,12 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/aura-studio/nano/cluster/clusterpb"
 	"github.com/aura-studio/nano/log"
+	"google.golang.org/protobuf/proto"
 )
 
 // cluster represents a nano cluster, which contains a bunch of nano nodes
@@ -142,7 +144,10 @@ func (c *cluster) Unregister(_ context.Context, req *clusterpb.UnregisterRequest
 
 	var index = -1
 	resp := &clusterpb.UnregisterResponse{}
-	for i, m := range c.members {
+	c.mu.RLock()
+	members := append([]*Member(nil), c.members...)
+	c.mu.RUnlock()
+	for i, m := range members {
 		if m.memberInfo.ServiceAddr == req.ServiceAddr {
 			index = i
 			break
@@ -154,7 +159,7 @@ func (c *cluster) Unregister(_ context.Context, req *clusterpb.UnregisterRequest
 
 	// Notify registered node to update remote services
 	delMember := &clusterpb.DelMemberRequest{ServiceAddr: req.ServiceAddr}
-	for _, m := range c.members {
+	for _, m := range members {
 		if m.MemberInfo().ServiceAddr == c.currentNode.ServiceAddr {
 			continue
 		}
@@ -177,10 +182,12 @@ func (c *cluster) Unregister(_ context.Context, req *clusterpb.UnregisterRequest
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if index == len(c.members)-1 {
-		c.members = c.members[:index]
-	} else {
-		c.members = append(c.members[:index], c.members[index+1:]...)
+	// members may be changed by other unregisters in the meantime
+	for i, m := range c.members {
+		if m.memberInfo.ServiceAddr == req.ServiceAddr {
+			c.members = append(c.members[:i], c.members[i+1:]...)
+			break
+		}
 	}
 
 	if c.currentNode.MasterPersist != nil {
@@ -269,3 +276,90 @@ func (c *cluster) delMember(addr string) {
 	}
 	c.mu.Unlock()
 }
+
+// Drain implements the MasterServer gRPC service, new sessions are not routed
+// to the draining member, but sessions bound to it are not affected
+func (c *cluster) Drain(_ context.Context, req *clusterpb.DrainRequest) (*clusterpb.DrainResponse, error) {
+	if !c.drainMember(req.ServiceAddr) {
+		return nil, fmt.Errorf("address %s has not registered", req.ServiceAddr)
+	}
+
+	// Notify registered node to stop routing new sessions, failures of some
+	// members do not stop notifying others
+	c.mu.RLock()
+	var addrs []string
+	for _, m := range c.members {
+		if m.isMaster || m.memberInfo.ServiceAddr == c.currentNode.ServiceAddr {
+			continue
+		}
+		addrs = append(addrs, m.memberInfo.ServiceAddr)
+	}
+	c.mu.RUnlock()
+
+	var errs []string
+	drainMember := &clusterpb.DrainMemberRequest{ServiceAddr: req.ServiceAddr}
+	for _, addr := range addrs {
+		pool, err := c.rpcClient.getConnPool(addr)
+		if err != nil {
+			log.Warnln("Drain member failed", addr, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", addr, err))
+			continue
+		}
+		client := clusterpb.NewMemberClient(pool.Get())
+		_, err = client.DrainMember(context.Background(), drainMember)
+		if err != nil {
+			log.Warnln("Drain member failed", addr, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", addr, err))
+		}
+	}
+
+	log.Infoln("Peer draining in cluster", req.ServiceAddr)
+
+	c.currentNode.handler.drainMember(req.ServiceAddr)
+
+	if c.currentNode.MasterPersist != nil {
+		var memberInfos []*clusterpb.MemberInfo
+		c.mu.RLock()
+		for _, member := range c.members {
+			if member.isMaster {
+				continue
+			}
+			memberInfos = append(memberInfos, member.MemberInfo())
+		}
+		c.mu.RUnlock()
+		if err := c.currentNode.MasterPersist.Set(memberInfos); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("drain %s: %s", req.ServiceAddr, strings.Join(errs, "; "))
+	}
+	return &clusterpb.DrainResponse{}, nil
+}
+
+// drainMember marks the member draining, returns false if not found
+func (c *cluster) drainMember(addr string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, member := range c.members {
+		if member.memberInfo.ServiceAddr == addr {
+			info := proto.Clone(member.memberInfo).(*clusterpb.MemberInfo)
+			info.Draining = true
+			member.memberInfo = info
+			return true
+		}
+	}
+	return false
+}
+
+// members returns a copy of members
+func (c *cluster) memberList() []*Member {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	members := make([]*Member, len(c.members))
+	for i, m := range c.members {
+		members[i] = &Member{isMaster: m.isMaster, memberInfo: m.memberInfo}
+	}
+	return members
+}
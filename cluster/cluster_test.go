@@ -0,0 +1,30 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aura-studio/nano/cluster/clusterpb"
+)
+
+func TestClusterDrain(t *testing.T) {
+	n := &Node{Options: Options{IsMaster: true}, ServiceAddr: "master"}
+	n.handler = NewHandler()
+	c := newCluster(n)
+	client := newRPCClient(nil)
+	client.closePool()
+	c.setRPCClient(client)
+	c.members = []*Member{
+		{isMaster: true, memberInfo: &clusterpb.MemberInfo{ServiceAddr: "master"}},
+		{memberInfo: &clusterpb.MemberInfo{ServiceAddr: "node-1"}},
+		{memberInfo: &clusterpb.MemberInfo{ServiceAddr: "node-2"}},
+	}
+
+	// failures of notifying members are returned after the member is drained
+	if _, err := c.Drain(context.Background(), &clusterpb.DrainRequest{ServiceAddr: "node-2"}); err == nil {
+		t.Fatal("errors of notifying members are not returned")
+	}
+	if !c.members[2].MemberInfo().Draining || !n.handler.isDraining("node-2") {
+		t.Fatal("member is not drained")
+	}
+}
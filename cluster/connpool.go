@@ -23,6 +23,7 @@ package cluster
 import (
 	"context"
 	"errors"
+	"net"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -40,26 +41,27 @@ type rpcClient struct {
 	sync.RWMutex
 	isClosed bool
 	pools    map[string]*connPool
+	dialer   func(ctx context.Context, addr string) (net.Conn, error)
 }
 
-func newConnArray(maxSize uint, addr string) (*connPool, error) {
+func newConnArray(maxSize uint, addr string, opts []grpc.DialOption) (*connPool, error) {
 	a := &connPool{
 		index: 0,
 		v:     make([]*grpc.ClientConn, maxSize),
 	}
-	if err := a.init(addr); err != nil {
+	if err := a.init(addr, opts); err != nil {
 		return nil, err
 	}
 	return a, nil
 }
 
-func (a *connPool) init(addr string) error {
+func (a *connPool) init(addr string, opts []grpc.DialOption) error {
 	for i := range a.v {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		conn, err := grpc.DialContext(
 			ctx,
 			addr,
-			env.GrpcOptions...,
+			opts...,
 		)
 		cancel()
 		if err != nil {
@@ -90,9 +92,10 @@ func (a *connPool) Close() {
 	}
 }
 
-func newRPCClient() *rpcClient {
+func newRPCClient(dialer func(ctx context.Context, addr string) (net.Conn, error)) *rpcClient {
 	return &rpcClient{
-		pools: make(map[string]*connPool),
+		pools:  make(map[string]*connPool),
+		dialer: dialer,
 	}
 }
 
@@ -121,7 +124,11 @@ func (c *rpcClient) createConnPool(addr string) (*connPool, error) {
 	if !ok {
 		var err error
 		// TODO: make conn count configurable
-		array, err = newConnArray(10, addr)
+		opts := env.GrpcOptions
+		if c.dialer != nil {
+			opts = append(opts[:len(opts):len(opts)], grpc.WithContextDialer(c.dialer))
+		}
+		array, err = newConnArray(10, addr, opts)
 		if err != nil {
 			return nil, err
 		}
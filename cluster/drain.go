@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/aura-studio/nano/log"
+)
+
+// drainInterval is the interval of checking whether sessions are finished
+var drainInterval = 100 * time.Millisecond
+
+func (n *Node) isDraining() bool {
+	return atomic.LoadInt32(&n.draining) == 1
+}
+
+func (n *Node) sessionCount() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return len(n.sessions)
+}
+
+// GracefulShutdown drains the node and then shuts it down. New client
+// connections are rejected, the master stops routing new sessions to the node
+// and clients are notified by DrainRoute, the node shuts down once all sessions
+// are closed or the timeout expires.
+func (n *Node) GracefulShutdown(timeout time.Duration) {
+	if !atomic.CompareAndSwapInt32(&n.draining, 0, 1) {
+		return
+	}
+
+	n.mu.RLock()
+	listener := n.listener
+	n.mu.RUnlock()
+	if listener != nil {
+		listener.Close()
+	}
+
+	if n.IsMaster || n.AdvertiseAddr != "" {
+		if err := n.Drain(n.ServiceAddr); err != nil {
+			log.Errorf("nano/drain: drain %s error: %v", n.ServiceAddr, err)
+		}
+	}
+
+	if n.DrainRoute != "" {
+		n.mu.RLock()
+		for _, s := range n.sessions {
+			// only clients of the gate are notified
+			if _, ok := s.NetworkEntity().(*agent); !ok {
+				continue
+			}
+			if err := s.Push(n.DrainRoute, n.DrainMessage); err != nil {
+				log.Errorf("nano/drain: push %s to session %d error: %v", n.DrainRoute, s.ID(), err)
+			}
+		}
+		n.mu.RUnlock()
+	}
+
+	log.Infof("Draining %d sessions in %v", n.sessionCount(), timeout)
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+WAIT:
+	for n.sessionCount() > 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			log.Infof("Drain timeout, %d sessions remain", n.sessionCount())
+			break WAIT
+		}
+	}
+
+	n.Shutdown()
+}
@@ -0,0 +1,120 @@
+package cluster_test
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano"
+	"github.com/aura-studio/nano/benchmark/testdata"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/env"
+	"github.com/aura-studio/nano/nanotest"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	c := nanotest.NewCluster(t)
+	defer c.Close()
+
+	c.AddMaster()
+	gate := c.AddNode(nano.WithDrainNotice("onMigrate", &testdata.Pong{Content: "migrating"}))
+	for _, addr := range []string{"node-2", "node-3"} {
+		comps := &component.Components{}
+		comps.Register(&RoomComponent{addr: addr})
+		c.AddNode(nano.WithComponents(comps))
+	}
+
+	server := httptest.NewServer(gate.AdminHandler())
+	defer server.Close()
+
+	client := gate.Connect()
+	defer client.Close()
+	pong := &testdata.Pong{}
+	client.MustCall("RoomComponent.Join", &testdata.Ping{}, pong)
+	bound := pong.Content
+
+	// drain the backend bound to the session
+	backend := c.Nodes()[1]
+	if bound == "node-3" {
+		backend = c.Nodes()[2]
+	}
+	drained := make(chan struct{})
+	go func() {
+		backend.GracefulShutdown(nanotest.DefaultTimeout)
+		close(drained)
+	}()
+
+	deadline := time.Now().Add(nanotest.DefaultTimeout)
+	for {
+		var members []struct {
+			ServiceAddr string
+			Draining    bool
+		}
+		getJSON(t, server, "/admin/members", &members)
+		if len(members) == 4 && members[2].Draining == (bound == "node-2") && members[3].Draining == (bound == "node-3") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backend is not draining: %+v", members)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	other := gate.Connect()
+	other.MustCall("RoomComponent.Join", &testdata.Ping{}, pong)
+	other.Close()
+	if pong.Content == bound {
+		t.Fatalf("new session routed to draining backend %s", bound)
+	}
+
+	// sessions bound to the draining backend are still served
+	client.MustCall("RoomComponent.Join", &testdata.Ping{}, pong)
+	if pong.Content != bound {
+		t.Fatalf("session routed to %s, want %s", pong.Content, bound)
+	}
+	select {
+	case <-drained:
+		t.Fatal("backend shut down before sessions finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	client.Close()
+	select {
+	case <-drained:
+	case <-time.After(nanotest.DefaultTimeout):
+		t.Fatal("backend is not shut down after sessions finished")
+	}
+
+	// drain the gate after nano.Shutdown, clients are notified and new
+	// connections are rejected
+	client = gate.Connect()
+	defer client.Close()
+	disconnected := make(chan struct{})
+	client.OnDisconnected(func(interface{}) { close(disconnected) })
+	client.MustCall("RoomComponent.Join", &testdata.Ping{}, pong)
+	die := env.Die
+	env.Die = make(chan bool)
+	close(env.Die)
+	defer func() { env.Die = die }()
+	go gate.GracefulShutdown(200 * time.Millisecond)
+
+	client.ExpectPush("onMigrate", pong)
+	if pong.Content != "migrating" {
+		t.Fatalf("unexpected drain notice: %v", pong)
+	}
+
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	gate.ServeConn(peer)
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("connection is not rejected by draining gate")
+	}
+
+	// remaining sessions are closed after the drain timeout
+	select {
+	case <-disconnected:
+	case <-time.After(nanotest.DefaultTimeout):
+		t.Fatal("session is not closed after drained")
+	}
+}
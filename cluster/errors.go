@@ -26,4 +26,9 @@ import "errors"
 var (
 	ErrCloseClosedSession = errors.New("close closed session")
 	ErrInvalidRegisterReq = errors.New("invalid register request")
+
+	ErrSessionNotFound       = errors.New("session not found")
+	ErrAdminResourceNotFound = errors.New("admin resource not found")
+	ErrAdminUnauthorized     = errors.New("admin unauthorized")
+	ErrRateLimited           = errors.New("rate limited")
 )
@@ -0,0 +1,569 @@
+package cluster_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano"
+	"github.com/aura-studio/nano/admission"
+	"github.com/aura-studio/nano/auth"
+	"github.com/aura-studio/nano/benchmark/testdata"
+	"github.com/aura-studio/nano/cluster"
+	"github.com/aura-studio/nano/codec"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/connector"
+	"github.com/aura-studio/nano/log"
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/metrics"
+	"github.com/aura-studio/nano/nanotest"
+	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/ratelimit"
+	"github.com/aura-studio/nano/replay"
+	"github.com/aura-studio/nano/serialize/json"
+	"github.com/aura-studio/nano/session"
+	"github.com/aura-studio/nano/tracing"
+)
+
+type BackendComponent struct{ component.Base }
+
+func (c *BackendComponent) Ping(s *session.Session, ping *testdata.Ping) error {
+	if err := s.Push("onPing", &testdata.Pong{Content: "push:" + ping.Content}); err != nil {
+		return err
+	}
+	return s.Response("BackendComponent.Ping", &testdata.Pong{Content: "backend:" + ping.Content})
+}
+
+func (c *BackendComponent) Secret(s *session.Session, ping *testdata.Ping) error {
+	return s.Response("BackendComponent.Secret", &testdata.Pong{Content: "secret"})
+}
+
+func TestGatePipeline(t *testing.T) {
+	var mu sync.Mutex
+	var forwarded, returned []string
+	pipe := pipeline.New()
+	pipe.Forward().Use("audit", "BackendComponent.*", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		mu.Lock()
+		forwarded = append(forwarded, msg.Route)
+		mu.Unlock()
+		return next(s, msg)
+	})
+	pipe.Forward().Use("deny", "*.Secret", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		return pipeline.NewError(403, "forbidden")
+	})
+	pipe.Backend().Use("audit", "", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		mu.Lock()
+		returned = append(returned, msg.Type.String()+":"+msg.Route)
+		mu.Unlock()
+		return next(s, msg)
+	})
+	pipe.Backend().Use("drop", "onPing", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		return nil
+	})
+
+	c := nanotest.NewCluster(t)
+	defer c.Close()
+
+	backendComps := &component.Components{}
+	backendComps.Register(&BackendComponent{}, component.WithSerializer(json.NewSerializer()))
+
+	c.AddMaster()
+	gate := c.AddNode(nano.WithPipeline(pipe))
+	c.AddNode(nano.WithComponents(backendComps))
+
+	client := gate.Connect(connector.WithSerializer(json.NewSerializer()))
+	defer client.Close()
+
+	pong := &testdata.Pong{}
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{Content: "a"}, pong)
+	if pong.Content != "backend:a" {
+		t.Fatalf("unexpected response: %v", pong)
+	}
+	client.ExpectNoPush("onPing", 20*time.Millisecond)
+
+	if e := client.MustCallError("BackendComponent.Secret", &testdata.Ping{}); e.Code != 403 {
+		t.Fatalf("unexpected response: %v", e)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(forwarded) != 2 || forwarded[0] != "BackendComponent.Ping" || forwarded[1] != "BackendComponent.Secret" {
+		t.Fatalf("unexpected forwarded: %v", forwarded)
+	}
+	if len(returned) != 2 || returned[0] != "Push:onPing" || returned[1] != "Response:BackendComponent.Ping" {
+		t.Fatalf("unexpected returned: %v", returned)
+	}
+}
+
+func TestGateErrorResponse(t *testing.T) {
+	gatePipe, backendPipe := pipeline.New(), pipeline.New()
+	gatePipe.Forward().Use("deny", "*.Secret", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		return pipeline.NewError(403, "forbidden")
+	})
+	backendPipe.Inbound().Use("deny", "*.Ping", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		return pipeline.NewError(451, "unavailable")
+	})
+
+	c := nanotest.NewCluster(t)
+	defer c.Close()
+
+	// errors are not serialized by the default protobuf serializer
+	backendComps := &component.Components{}
+	backendComps.Register(&BackendComponent{})
+
+	c.AddMaster()
+	gate := c.AddNode(nano.WithPipeline(gatePipe))
+	c.AddNode(nano.WithComponents(backendComps), nano.WithPipeline(backendPipe))
+
+	client := gate.Connect()
+	defer client.Close()
+
+	if e := client.MustCallError("BackendComponent.Secret", &testdata.Ping{}); e.Code != 403 || e.Message != "forbidden" {
+		t.Fatalf("unexpected error of gate: %v", e)
+	}
+	if e := client.MustCallError("BackendComponent.Ping", &testdata.Ping{}); e.Code != 451 || e.Message != "unavailable" {
+		t.Fatalf("unexpected error of backend: %v", e)
+	}
+}
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*tracing.SpanData
+}
+
+func (r *spanRecorder) Export(spans []*tracing.SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func TestGateTracing(t *testing.T) {
+	recorder := &spanRecorder{}
+	tracing.SetExporter(recorder)
+	defer tracing.SetExporter(nil)
+
+	c := nanotest.NewCluster(t)
+	defer c.Close()
+
+	backendComps := &component.Components{}
+	backendComps.Register(&BackendComponent{})
+
+	c.AddMaster()
+	gate := c.AddNode()
+	c.AddNode(nano.WithComponents(backendComps))
+
+	client := gate.Connect()
+	defer client.Close()
+
+	pong := &testdata.Pong{}
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{Content: "a"}, pong)
+	client.ExpectPush("onPing", pong)
+
+	// the handler span ends after the response is sent
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		tracing.Flush()
+		recorder.mu.Lock()
+		n := len(recorder.spans)
+		recorder.mu.Unlock()
+		if n >= 6 {
+			break
+		}
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	spans := map[string][]*tracing.SpanData{}
+	for _, s := range recorder.spans {
+		if s.TraceID != recorder.spans[0].TraceID {
+			t.Fatalf("unexpected trace of span %s", s.Name)
+		}
+		spans[s.Name] = append(spans[s.Name], s)
+	}
+	if len(spans["nano.receive"]) != 2 || len(spans["nano.forward"]) != 1 ||
+		len(spans["nano.handler"]) != 1 || len(spans["nano.backend"]) != 2 {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+
+	// gate receive -> forward -> backend receive -> handler -> push and response
+	forward, handler := spans["nano.forward"][0], spans["nano.handler"][0]
+	var gateReceive, backendReceive *tracing.SpanData
+	for _, s := range spans["nano.receive"] {
+		if s.ParentSpanID == forward.SpanID {
+			backendReceive = s
+		} else {
+			gateReceive = s
+		}
+	}
+	if backendReceive == nil || forward.ParentSpanID != gateReceive.SpanID || handler.ParentSpanID != backendReceive.SpanID {
+		t.Fatalf("unexpected span tree: %v", spans)
+	}
+	for _, s := range spans["nano.backend"] {
+		if s.ParentSpanID != handler.SpanID {
+			t.Fatalf("unexpected parent of %s", s.Attributes["nano.route"])
+		}
+	}
+}
+
+func TestGateRateLimit(t *testing.T) {
+	comps := &component.Components{}
+	comps.Register(&BackendComponent{}, component.WithSerializer(json.NewSerializer()))
+	server := nanotest.NewServer(t, nano.WithComponents(comps), nano.WithRateLimit(
+		ratelimit.Rule{Key: ratelimit.BySession, Pattern: "*.Ping", Rate: 0.001, Burst: 1, Action: ratelimit.Respond, Code: 429},
+		ratelimit.Rule{Key: ratelimit.BySession, Pattern: "*.Secret", Rate: 0.001, Burst: 1, Action: ratelimit.Disconnect},
+	))
+	defer server.Close()
+
+	client := server.Connect(connector.WithSerializer(json.NewSerializer()))
+	defer client.Close()
+	disconnected := make(chan struct{})
+	client.OnDisconnected(func(interface{}) { close(disconnected) })
+
+	pong := &testdata.Pong{}
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{Content: "a"}, pong)
+	if e := client.MustCallError("BackendComponent.Ping", &testdata.Ping{Content: "b"}); e.Code != 429 {
+		t.Fatalf("unexpected response: %v", e)
+	}
+	if n := metrics.RateLimited.Value("session", "respond", "BackendComponent.Ping"); n != 1 {
+		t.Fatalf("%v messages limited", n)
+	}
+
+	client.MustCall("BackendComponent.Secret", &testdata.Ping{}, pong)
+	client.MustNotify("BackendComponent.Secret", &testdata.Ping{})
+	select {
+	case <-disconnected:
+	case <-time.After(nanotest.DefaultTimeout):
+		t.Fatal("limited client is not disconnected")
+	}
+}
+
+type (
+	PushComponent struct{ component.Base }
+
+	// pushPayload can not be serialized by the default protobuf serializer
+	pushPayload struct {
+		Content string `json:"content"`
+	}
+)
+
+func (c *PushComponent) Echo(s *session.Session, p *pushPayload) error {
+	return s.Push("onPushEcho", p)
+}
+
+func TestGateInvalidRateLimit(t *testing.T) {
+	opts := &cluster.Options{}
+	nano.WithRateLimit(ratelimit.Rule{Key: ratelimit.BySession, Rate: 1})(opts)
+	node := &cluster.Node{Options: *opts, ServiceAddr: "127.0.0.1:0"}
+	if err := node.Startup(); err != ratelimit.ErrInvalidRule {
+		t.Fatalf("node starts up with invalid rule: %v", err)
+	}
+}
+
+func TestGatePushSerializer(t *testing.T) {
+	c := nanotest.NewCluster(t)
+	defer c.Close()
+
+	backendComps := &component.Components{}
+	backendComps.Register(&PushComponent{}, component.WithSerializer(json.NewSerializer()),
+		component.WithPush("onPushEcho", &pushPayload{}))
+
+	c.AddMaster()
+	gate := c.AddNode()
+	c.AddNode(nano.WithComponents(backendComps))
+
+	client := gate.Connect(connector.WithSerializer(json.NewSerializer()))
+	defer client.Close()
+	client.MustNotify("PushComponent.Echo", &pushPayload{Content: "hello"})
+	p := &pushPayload{}
+	client.ExpectPush("onPushEcho", p)
+	if p.Content != "hello" {
+		t.Fatalf("unexpected push: %+v", p)
+	}
+}
+
+// gauge returns the value of metric without labels in the default registry
+func gauge(t *testing.T, name string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := metrics.Default.Collect(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, name+" ") {
+			return strings.TrimPrefix(line, name+" ")
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return ""
+}
+
+// waitGauge waits until the value of metric is v
+func waitGauge(t *testing.T, name, v string) {
+	t.Helper()
+	deadline := time.Now().Add(nanotest.DefaultTimeout)
+	for gauge(t, name) != v {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s is %s, want %s", name, gauge(t, name), v)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGateSessionsGauge(t *testing.T) {
+	comps := &component.Components{}
+	comps.Register(&BackendComponent{}, component.WithSerializer(json.NewSerializer()))
+	server := nanotest.NewServer(t, nano.WithComponents(comps))
+	defer server.Close()
+
+	// sessions of former tests are closed
+	waitGauge(t, "nano_sessions", "0")
+
+	client := server.Connect(connector.WithSerializer(json.NewSerializer()))
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{}, &testdata.Pong{})
+	if v := gauge(t, "nano_sessions"); v != "1" {
+		t.Fatalf("nano_sessions is %s", v)
+	}
+	client.Close()
+	waitGauge(t, "nano_sessions", "0")
+}
+
+func TestGateRouteLabels(t *testing.T) {
+	comps := &component.Components{}
+	comps.Register(&BackendComponent{}, component.WithSerializer(json.NewSerializer()))
+	server := nanotest.NewServer(t, nano.WithComponents(comps), nano.WithRateLimit(
+		ratelimit.Rule{Key: ratelimit.ByRoute, Pattern: "*", Rate: 0.001, Burst: 1, Action: ratelimit.Drop},
+	))
+	defer server.Close()
+
+	client := server.Connect(connector.WithSerializer(json.NewSerializer()))
+	defer client.Close()
+
+	// random routes from clients are labeled and limited as unknown routes
+	for _, route := range []string{"Random.Route1", "Random.Route2", "Random.Route3"} {
+		client.MustNotify(route, &testdata.Ping{})
+	}
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{}, &testdata.Pong{})
+
+	if n := metrics.Messages.Value(metrics.In, "Notify", "unknown"); n != 3 {
+		t.Fatalf("%v unknown routes received", n)
+	}
+	if n := metrics.Messages.Value(metrics.In, "Notify", "Random.Route1"); n != 0 {
+		t.Fatalf("random route is labeled")
+	}
+	if n := metrics.RateLimited.Value("route", "drop", "unknown"); n != 2 {
+		t.Fatalf("%v unknown routes limited", n)
+	}
+}
+
+func TestGateAdmission(t *testing.T) {
+	comps := &component.Components{}
+	comps.Register(&BackendComponent{}, component.WithSerializer(json.NewSerializer()))
+	server := nanotest.NewServer(t, nano.WithComponents(comps), nano.WithAdmission(admission.Config{
+		MaxSessions:      1,
+		HandshakeTimeout: 50 * time.Millisecond,
+	}))
+	defer server.Close()
+
+	// connections without a valid first packet are closed
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	go server.ServeConn(peer)
+	conn.SetReadDeadline(time.Now().Add(nanotest.DefaultTimeout))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("connection is not closed by handshake timeout: %v", err)
+	}
+
+	client := server.Connect(connector.WithSerializer(json.NewSerializer()))
+	defer client.Close()
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{}, &testdata.Pong{})
+
+	// connections over the limit are rejected
+	conn, peer = net.Pipe()
+	defer conn.Close()
+	go server.ServeConn(peer)
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("connection is not rejected: %v", err)
+	}
+	if n := metrics.Rejected.Value("max_sessions"); n != 1 {
+		t.Fatalf("%v connections rejected", n)
+	}
+}
+
+func TestGateHandshake(t *testing.T) {
+	comps := &component.Components{}
+	comps.Register(&BackendComponent{}, component.WithSerializer(json.NewSerializer()))
+	server := nanotest.NewServer(t, nano.WithComponents(comps), nano.WithAdmission(admission.Config{
+		HandshakeTimeout: 50 * time.Millisecond,
+	}))
+	defer server.Close()
+
+	// messages of unknown routes do not complete the handshake
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	go server.ServeConn(peer)
+	data, err := message.Encode(&message.Message{Type: message.Notify, Route: "Random.Route"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := codec.Encode(data)
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(nanotest.DefaultTimeout))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("connection is not closed by handshake timeout: %v", err)
+	}
+
+	// the handshake is completed by a valid message
+	client := server.Connect(connector.WithSerializer(json.NewSerializer()))
+	defer client.Close()
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{}, &testdata.Pong{})
+	time.Sleep(100 * time.Millisecond)
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{}, &testdata.Pong{})
+}
+
+func TestGateLogSampleRate(t *testing.T) {
+	defer log.SetSampleRate(log.SampleRate())
+	log.SetSampleRate(0)
+
+	opt := &cluster.Options{}
+	nano.WithLogSampleRate(1)(opt)
+	if log.SampleRate() != 0 {
+		t.Fatal("sample rate is set before the node starts")
+	}
+
+	server := nanotest.NewServer(t, nano.WithLogSampleRate(1))
+	defer server.Close()
+	if log.SampleRate() != 1 {
+		t.Fatalf("sample rate %v is not set", log.SampleRate())
+	}
+}
+
+type AccountComponent struct{ component.Base }
+
+func (c *AccountComponent) Login(s *session.Session, ping *testdata.Ping) error {
+	return s.Response("AccountComponent.Login", &testdata.Pong{Content: strconv.FormatInt(s.UID(), 10)})
+}
+
+func (c *AccountComponent) Guest(s *session.Session, ping *testdata.Ping) error {
+	return s.Response("AccountComponent.Guest", &testdata.Pong{Content: "guest"})
+}
+
+func TestGateAuth(t *testing.T) {
+	c := nanotest.NewCluster(t)
+	defer c.Close()
+
+	backendComps := &component.Components{}
+	backendComps.Register(&BackendComponent{}, component.WithSerializer(json.NewSerializer()))
+	backendComps.Register(&AccountComponent{}, component.WithSerializer(json.NewSerializer()),
+		component.WithAnonymousRoutes((*AccountComponent).Guest))
+
+	c.AddMaster()
+	gate := c.AddNode(nano.WithAuth(auth.Config{
+		LoginRoute: "AccountComponent.Login",
+		Login: func(s *session.Session, data []byte) (int64, error) {
+			ping := &testdata.Ping{}
+			if err := json.NewSerializer().Unmarshal(data, ping); err != nil {
+				return 0, err
+			}
+			if ping.Content != "token" {
+				return 0, errors.New("invalid token")
+			}
+			return 1001, nil
+		},
+	}))
+	c.AddNode(nano.WithComponents(backendComps))
+
+	client := gate.Connect(connector.WithSerializer(json.NewSerializer()))
+	defer client.Close()
+
+	if e := client.MustCallError("BackendComponent.Ping", &testdata.Ping{}); e.Code != auth.DefaultCode {
+		t.Fatalf("unexpected response before login: %v", e)
+	}
+
+	pong := &testdata.Pong{}
+	client.MustCall("AccountComponent.Guest", &testdata.Ping{}, pong)
+	if pong.Content != "guest" {
+		t.Fatalf("unexpected response of anonymous route: %v", pong)
+	}
+
+	if e := client.MustCallError("AccountComponent.Login", &testdata.Ping{Content: "bad"}); e.Code != auth.DefaultCode {
+		t.Fatalf("unexpected response of invalid login: %v", e)
+	}
+
+	client.MustCall("AccountComponent.Login", &testdata.Ping{Content: "token"}, pong)
+	if pong.Content != "1001" {
+		t.Fatalf("unexpected response of login: %v", pong)
+	}
+	client.MustCall("BackendComponent.Ping", &testdata.Ping{Content: "a"}, pong)
+	if pong.Content != "backend:a" {
+		t.Fatalf("unexpected response after login: %v", pong)
+	}
+}
+
+func TestGateReplay(t *testing.T) {
+	c := nanotest.NewCluster(t)
+	defer c.Close()
+
+	backendComps := &component.Components{}
+	backendComps.Register(&BackendComponent{}, component.WithSerializer(json.NewSerializer()))
+	backendComps.Register(&AccountComponent{}, component.WithSerializer(json.NewSerializer()))
+
+	c.AddMaster()
+	gate := c.AddNode(
+		nano.WithAuth(auth.Config{
+			LoginRoute: "AccountComponent.Login",
+			Login: func(s *session.Session, data []byte) (int64, error) {
+				replay.SetSecret(s, []byte("secret"))
+				return s.ID(), nil
+			},
+		}),
+		nano.WithReplayProtection(replay.Config{}),
+	)
+	c.AddNode(nano.WithComponents(backendComps))
+
+	login := func(secret string) *nanotest.Client {
+		client := gate.Connect(connector.WithSerializer(json.NewSerializer()))
+		client.MustCall("AccountComponent.Login", &testdata.Ping{}, &testdata.Pong{})
+		client.SetSecret([]byte(secret))
+		return client
+	}
+
+	signed := login("secret")
+	defer signed.Close()
+
+	// concurrent requests are queued in the order of ids, which are accepted
+	// by the strictly increasing window
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pong := &testdata.Pong{}
+			if err := signed.Call("BackendComponent.Ping", &testdata.Ping{Content: "a"}, pong); err != nil {
+				errs <- err
+			} else if pong.Content != "backend:a" {
+				errs <- errors.New("unexpected response: " + pong.Content)
+			}
+		}()
+		signed.MustNotify("BackendComponent.Ping", &testdata.Ping{Content: "n"})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("signed request failed: %v", err)
+	}
+
+	forged := login("forged")
+	defer forged.Close()
+	if e := forged.MustCallError("BackendComponent.Ping", &testdata.Ping{Content: "a"}); e.Code != replay.DefaultCode {
+		t.Fatalf("unexpected response of forged request: %v", e)
+	}
+}
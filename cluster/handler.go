@@ -33,14 +33,21 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aura-studio/nano/auth"
 	"github.com/aura-studio/nano/cluster/clusterpb"
 	"github.com/aura-studio/nano/component"
 	"github.com/aura-studio/nano/env"
 	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/metrics"
 	"github.com/aura-studio/nano/packet"
 	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/ratelimit"
+	"github.com/aura-studio/nano/replay"
+	"github.com/aura-studio/nano/schema"
+	"github.com/aura-studio/nano/service"
 	"github.com/aura-studio/nano/session"
+	"github.com/aura-studio/nano/tracing"
 )
 
 type rpcHandler func(session *session.Session, msg *message.Message, noCopy bool)
@@ -53,11 +60,30 @@ type LocalHandler struct {
 	mu             sync.RWMutex
 	remoteServices map[string]map[string][]*clusterpb.MemberInfo
 	versionDict    map[uint32]string
+	draining       map[string]bool // service address of draining members
+	policies       []VersionPolicy
+	remoteRoutes   map[string]map[string]bool // remote routes to whether anonymous by member address
 
 	pipeline    pipeline.Pipeline
 	currentNode *Node
 }
 
+// unknownRoute is the metric label of routes not registered by any handler
+const unknownRoute = "unknown"
+
+// knownRoutes are routes of local and remote handlers, routes from clients are
+// only used as metric labels if known, so that clients cannot create unlimited
+// series by random routes
+var knownRoutes sync.Map
+
+// routeLabel returns the metric label of route
+func routeLabel(route string) string {
+	if _, ok := knownRoutes.Load(route); ok {
+		return route
+	}
+	return unknownRoute
+}
+
 // newHandler creates a new LocalHandler
 func newHandler(currentNode *Node) *LocalHandler {
 	h := &LocalHandler{
@@ -65,6 +91,8 @@ func newHandler(currentNode *Node) *LocalHandler {
 		localHandlers:  make(map[string]*component.Handler),
 		remoteServices: map[string]map[string][]*clusterpb.MemberInfo{},
 		versionDict:    map[uint32]string{},
+		draining:       map[string]bool{},
+		remoteRoutes:   map[string]map[string]bool{},
 		pipeline:       currentNode.Pipeline,
 		currentNode:    currentNode,
 	}
@@ -78,6 +106,9 @@ func NewHandler() *LocalHandler {
 		localServices:  make(map[string]*component.Service),
 		localHandlers:  make(map[string]*component.Handler),
 		remoteServices: map[string]map[string][]*clusterpb.MemberInfo{},
+		versionDict:    map[uint32]string{},
+		draining:       map[string]bool{},
+		remoteRoutes:   map[string]map[string]bool{},
 	}
 
 	return h
@@ -95,18 +126,44 @@ func (h *LocalHandler) Register(comp component.Component, opts []component.Optio
 		return err
 	}
 
+	// custom serializers must be registered to be recognized by cluster members
+	for name, handler := range s.Handlers {
+		if handler.Serializer != nil && serializerType(handler) == message.Unknown {
+			return fmt.Errorf("handler: serializer %T of %s.%s is not registered", handler.Serializer, s.Name, name)
+		}
+	}
+	if s.Serializer != nil && message.GetSerializerType(s.Serializer) == message.Unknown {
+		return fmt.Errorf("handler: serializer %T of %s is not registered", s.Serializer, s.Name)
+	}
+
 	// register all localHandlers
 	h.localServices[s.Name] = s
 	for name, handler := range s.Handlers {
 		n := fmt.Sprintf("%s.%s", s.Name, name)
 		h.localHandlers[n] = handler
+		knownRoutes.Store(n, struct{}{})
 		message.WriteDictionaryItem(n, handler.Code)
-		message.WriteSerializerItem(n, uint16(env.SerializerType))
+		message.WriteSerializerItem(n, serializerType(handler))
+	}
+	// declared push routes are serialized by the serializer of component,
+	// pushes of routes not declared use the application serializer
+	if s.Serializer != nil {
+		for route := range s.Pushes {
+			message.WriteSerializerItem(route, message.GetSerializerType(s.Serializer))
+		}
 	}
 
 	return nil
 }
 
+// serializerType returns the serializer type of handler
+func serializerType(handler *component.Handler) uint16 {
+	if handler.Serializer == nil {
+		return env.SerializerType
+	}
+	return message.GetSerializerType(handler.Serializer)
+}
+
 func (h *LocalHandler) initMembers(members []*clusterpb.MemberInfo) {
 	for _, m := range members {
 		h.addMember(m)
@@ -119,6 +176,9 @@ func (h *LocalHandler) addMember(member *clusterpb.MemberInfo) {
 
 	v := member.Version
 	l := member.Label
+	if member.Draining {
+		h.draining[member.ServiceAddr] = true
+	}
 	for _, s := range member.Services {
 		if member.Version != "" {
 			log.Infof("Register remote service %s(%s) from %s", s, v, l)
@@ -137,6 +197,11 @@ func (h *LocalHandler) addMember(member *clusterpb.MemberInfo) {
 	for _, d := range member.Dictionary {
 		dictionary[d.Route] = uint16(d.Code)
 		serializers[d.Route] = uint16(d.Serializer)
+		if _, ok := h.remoteRoutes[d.Route]; !ok {
+			h.remoteRoutes[d.Route] = map[string]bool{}
+		}
+		h.remoteRoutes[d.Route][member.ServiceAddr] = d.Anonymous
+		knownRoutes.Store(d.Route, struct{}{})
 	}
 	message.WriteDictionary(dictionary)
 	message.WriteSerializers(serializers)
@@ -146,6 +211,14 @@ func (h *LocalHandler) delMember(addr string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	delete(h.draining, addr)
+	for route, members := range h.remoteRoutes {
+		delete(members, addr)
+		if len(members) == 0 {
+			delete(h.remoteRoutes, route)
+		}
+	}
+
 	for s, versionServices := range h.remoteServices {
 		for v, members := range versionServices {
 			for i, member := range members {
@@ -248,12 +321,22 @@ func (h *LocalHandler) LocalDictionary() []*clusterpb.DictionaryItem {
 			Route:      name,
 			Code:       uint32(handler.Code),
 			Type:       handler.Type.String(),
-			Serializer: uint32(env.SerializerType),
+			Serializer: uint32(serializerType(handler)),
+			Anonymous:  handler.Anonymous,
 		})
 	}
 	return result
 }
 
+// Schema returns the schema of local services
+func (h *LocalHandler) Schema() *schema.Schema {
+	var services []*component.Service
+	for _, s := range h.localServices {
+		services = append(services, s)
+	}
+	return schema.New(env.Version, services)
+}
+
 // RouteHandler routes handler from localHandlers by route
 func (h *LocalHandler) RouteHandler(route string) (*component.Handler, error) {
 	handler, found := h.localHandlers[route]
@@ -263,19 +346,33 @@ func (h *LocalHandler) RouteHandler(route string) (*component.Handler, error) {
 	return handler, nil
 }
 
-func (h *LocalHandler) handle(conn net.Conn) {
+func (h *LocalHandler) handle(conn net.Conn, uid int64) {
 	// create a client agent and startup write gorontine
 	agent := newAgent(conn, h.pipeline, h.processMessage)
+	agent.nodeDie = h.currentNode.die
+	if uid != 0 {
+		// authenticated by handshake
+		agent.session.BindUID(uid)
+	}
 	h.currentNode.storeSession(agent.session)
+	service.Connections.Increment()
 
 	// startup write goroutine
 	go agent.write()
 
-	if env.Debug {
-		log.Infof("New session established: %s", agent.String())
-	}
+	debugSession(agent.session, "session established", "remote", conn.RemoteAddr())
 	session.Inited(agent.session)
 
+	// close connections which do not send a valid first message in time
+	var handshake *time.Timer
+	if ctrl := h.currentNode.admission; ctrl != nil && ctrl.HandshakeTimeout() > 0 {
+		handshake = time.AfterFunc(ctrl.HandshakeTimeout(), func() {
+			metrics.Rejected.Inc("handshake_timeout")
+			debugSession(agent.session, "session handshake timeout")
+			conn.Close()
+		})
+	}
+
 	// guarantee agent related resource be destroyed
 	defer func() {
 		if err := recover(); err != nil {
@@ -298,16 +395,13 @@ func (h *LocalHandler) handle(conn net.Conn) {
 				log.Errorln("Cannot closed session in remote address", remote, err)
 				continue
 			}
-			if env.Debug {
-				log.Infoln("Session Closed notify remote server success", remote)
-			}
+			debugSession(agent.session, "session closed notified", "member", remote)
 		}
 
 		agent.Close()
 		h.currentNode.deleteSession(agent.session)
-		if env.Debug {
-			log.Infof("Session read goroutine exit, SessionID=%d, UID=%d", agent.session.ID(), agent.session.UID())
-		}
+		service.Connections.Decrement()
+		debugSession(agent.session, "session read goroutine exit")
 	}()
 
 	// read loop
@@ -338,6 +432,10 @@ func (h *LocalHandler) handle(conn net.Conn) {
 				return
 			}
 		}
+		if handshake != nil && agent.accepted {
+			handshake.Stop()
+			handshake = nil
+		}
 	}
 }
 
@@ -348,6 +446,7 @@ func (h *LocalHandler) processPacket(agent *agent, p *packet.Packet) error {
 	if err != nil {
 		return err
 	}
+	metrics.Messages.Inc(metrics.In, msg.Type.String(), routeLabel(msg.Route))
 
 	if agent.recvPckCnt == 1 {
 		h.mu.RLock()
@@ -357,35 +456,218 @@ func (h *LocalHandler) processPacket(agent *agent, p *packet.Packet) error {
 		agent.session.BindVersion(version)
 
 		agent.compressed = compressed
-		if env.Debug {
-			if compressed {
-				log.Printf("Use compressed router mode for agent, SessionID=%d, Version=%s",
-					agent.session.ID(), agent.session.Version())
-			} else {
-				log.Printf("Use uncompressed router mode for agent, SessionID=%d, Version=%s",
-					agent.session.ID(), agent.session.Version())
+		debugSession(agent.session, "session router mode", "compressed", compressed)
+	}
+
+	agent.lastAt = time.Now().Unix()
+	if limiter := h.currentNode.limiter; limiter != nil {
+		// buckets of unknown routes are shared
+		if rule := limiter.Limit(agent.session, routeLabel(msg.Route)); rule != nil {
+			return h.limited(agent.session, msg, rule)
+		}
+	}
+	if config := h.currentNode.Replay; config != nil {
+		if err := h.checkReplay(config, agent, msg); err != nil {
+			metrics.Replayed.Inc(replayReason(err))
+			debugSession(agent.session, "message rejected", "route", msg.Route, "error", err)
+			if msg.Type == message.Request {
+				if err := agent.session.ResponseMid(msg.ID, msg.Route, config.Error()); err != nil {
+					log.Errorf("nano/handler: response %s error: %+v", msg.Route, err)
+				}
+			}
+			return nil
+		}
+	}
+	if config := h.currentNode.Auth; config != nil {
+		if err := h.authenticate(config, agent.session, msg); err != nil {
+			debugSession(agent.session, "message rejected", "route", msg.Route, "error", err)
+			if msg.Type == message.Request {
+				if err := agent.session.ResponseMid(msg.ID, msg.Route, config.Error()); err != nil {
+					log.Errorf("nano/handler: response %s error: %+v", msg.Route, err)
+				}
 			}
+			return nil
 		}
 	}
 
+	// the first message of a known route passed all checks completes the
+	// handshake
+	if msg.Type == message.Request || msg.Type == message.Notify {
+		agent.accepted = agent.accepted || routeLabel(msg.Route) != unknownRoute
+	}
 	h.processMessage(agent.session, msg, false)
+	return nil
+}
 
-	agent.lastAt = time.Now().Unix()
+// authenticate authenticates messages of the login route, and rejects messages
+// of routes not anonymous before the session is bound to a UID
+func (h *LocalHandler) authenticate(config *auth.Config, s *session.Session, msg *message.Message) error {
+	if config.Login != nil && msg.Route == config.LoginRoute {
+		uid, err := config.Login(s, msg.Data)
+		if err != nil {
+			return err
+		}
+		if uid == 0 {
+			return auth.ErrUnauthenticated
+		}
+		s.BindUID(uid)
+		return nil
+	}
+
+	if s.UID() != 0 || h.isAnonymous(msg.Route) {
+		return nil
+	}
+	return auth.ErrUnauthenticated
+}
+
+// checkReplay verifies the signature of message if the session has a secret,
+// which is stripped from the data, and rejects request IDs received before.
+// Signed notifies are numbered by IDs as requests, which are checked by the
+// same window and cleared after that.
+func (h *LocalHandler) checkReplay(config *replay.Config, agent *agent, msg *message.Message) error {
+	secret := config.SecretOf(agent.session)
+	if len(secret) > 0 {
+		data, err := replay.Verify(secret, msg.Type, msg.ID, msg.Route, msg.Data)
+		if err != nil {
+			return err
+		}
+		msg.Data = data
+	}
+
+	switch {
+	case msg.Type == message.Request:
+	case msg.Type == message.Notify && len(secret) > 0:
+		defer func() { msg.ID = 0 }()
+	default:
+		return nil
+	}
+	if agent.window == nil {
+		agent.window = replay.NewWindow(config.Window)
+	}
+	return agent.window.Check(msg.ID)
+}
+
+func replayReason(err error) string {
+	if err == replay.ErrInvalidSignature {
+		return "signature"
+	}
+	return "replayed"
+}
+
+// isAnonymous reports whether the route is callable before authenticated
+func (h *LocalHandler) isAnonymous(route string) bool {
+	if handler, found := h.localHandlers[route]; found {
+		return handler.Anonymous
+	}
+
+	// remote routes are anonymous only if all members mark them anonymous,
+	// since the member is not resolved yet
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	members := h.remoteRoutes[route]
+	for _, anonymous := range members {
+		if !anonymous {
+			return false
+		}
+	}
+	return len(members) > 0
+}
+
+// limited takes the action of rule on the message limited
+func (h *LocalHandler) limited(s *session.Session, msg *message.Message, rule *ratelimit.Rule) error {
+	metrics.RateLimited.Inc(rule.Key.String(), rule.Action.String(), routeLabel(msg.Route))
+	debugSession(s, "message rate limited", "route", msg.Route, "key", rule.Key, "action", rule.Action)
+
+	switch rule.Action {
+	case ratelimit.Respond:
+		if msg.Type != message.Request {
+			return nil
+		}
+		if err := s.ResponseMid(msg.ID, msg.Route, rule.Error()); err != nil {
+			log.Errorf("nano/handler: response %s error: %+v", msg.Route, err)
+		}
+	case ratelimit.Disconnect:
+		return ErrRateLimited
+	}
 	return nil
 }
 
-func (h *LocalHandler) findMembers(service string, shortVer uint32) (string, []*clusterpb.MemberInfo) {
+// drainMember stops routing new sessions to the member
+func (h *LocalHandler) drainMember(addr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.draining[addr] = true
+}
+
+// isDraining reports whether the member is draining
+func (h *LocalHandler) isDraining(addr string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.draining[addr]
+}
+
+func (h *LocalHandler) findMembers(s *session.Session, service string, shortVer uint32) (string, []*clusterpb.MemberInfo) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	version := h.versionDict[shortVer]
-	if len(h.remoteServices[service][version]) > 0 {
-		return version, h.remoteServices[service][version]
+	members := h.remoteServices[service][version]
+	if version == "" || len(members) == 0 {
+		// unversioned sessions are routed by version policies
+		if v := h.policyVersion(s, service); v != "" {
+			version, members = v, h.remoteServices[service][v]
+		} else {
+			members = h.remoteServices[service][""]
+		}
+	}
+	if len(h.draining) == 0 {
+		return version, members
+	}
+
+	// draining members are only selected if all members are draining
+	var available []*clusterpb.MemberInfo
+	for _, m := range members {
+		if !h.draining[m.ServiceAddr] {
+			available = append(available, m)
+		}
+	}
+	if len(available) == 0 {
+		return version, members
+	}
+	return version, available
+}
+
+func (h *LocalHandler) remoteProcess(ctx context.Context, s *session.Session, msg *message.Message, noCopy bool) {
+	pipe := h.pipeline
+	if pipe == nil {
+		h.forward(ctx, s, msg, noCopy)
+		return
+	}
+
+	pctx, span := tracing.Start(ctx, "nano.pipeline", tracing.KindInternal)
+	err := pipe.Forward().Handle(s, msg, func(s *session.Session, msg *message.Message) error {
+		h.forward(pctx, s, msg, noCopy)
+		return nil
+	})
+	span.SetError(err)
+	span.End()
+	if err == nil {
+		return
+	}
+
+	if e, ok := err.(*pipeline.Error); ok && msg.Type == message.Request {
+		if err := s.ResponseMid(msg.ID, msg.Route, e); err != nil {
+			log.Errorf("nano/handler: response %s error: %+v", msg.Route, err)
+		}
+		return
 	}
-	return version, h.remoteServices[service][""]
+	log.Errorf("nano/handler: forward %s error: %+v", msg.Route, err)
 }
 
-func (h *LocalHandler) remoteProcess(s *session.Session, msg *message.Message, noCopy bool) {
+// forward sends the message to a remote service
+func (h *LocalHandler) forward(ctx context.Context, s *session.Session, msg *message.Message, noCopy bool) {
 	index := strings.LastIndex(msg.Route, ".")
 	if index < 0 {
 		log.Errorf("nano/handler: invalid route %s", msg.Route)
@@ -393,16 +675,13 @@ func (h *LocalHandler) remoteProcess(s *session.Session, msg *message.Message, n
 	}
 
 	service := msg.Route[:index]
-	version, members := h.findMembers(service, msg.ShortVer)
+	version, members := h.findMembers(s, service, msg.ShortVer)
 	if len(members) == 0 {
 		log.Errorf("nano/handler: %s (version:%s) not found(forgot registered?)", msg.Route, version)
 		return
 	}
 
-	if env.Debug {
-		log.Infof("Type=%s, Route=%s, ID=%d, Version=%s, UID=%d, Mid=%d, Data=%dbytes",
-			msg.Type.String(), msg.Route, s.ID(), s.Version(), s.UID(), msg.ID, len(msg.Data))
-	}
+	debugMessage(s, msg.Type, msg.Route, msg.ID, msg.Data)
 
 	// Select a remote service address
 	// 1. Use the service address directly if the router contains binding item
@@ -434,7 +713,13 @@ func (h *LocalHandler) remoteProcess(s *session.Session, msg *message.Message, n
 		sessionID = v.sid
 	}
 
+	ctx, span := tracing.Start(ctx, "nano.forward", tracing.KindClient)
+	span.SetAttribute("nano.member", remoteAddr)
+	defer span.End()
+	ctx, traceParent := withTrace(ctx, span.Context())
+
 	client := clusterpb.NewMemberClient(pool.Get())
+	start := time.Now()
 	switch msg.Type {
 	case message.Request:
 		request := &clusterpb.RequestMessage{
@@ -449,8 +734,9 @@ func (h *LocalHandler) remoteProcess(s *session.Session, msg *message.Message, n
 				Network: s.RemoteAddr().Network(),
 				Addr:    s.RemoteAddr().String(),
 			},
+			TraceParent: traceParent,
 		}
-		_, err = client.HandleRequest(context.Background(), request)
+		_, err = client.HandleRequest(ctx, request)
 	case message.Notify:
 		request := &clusterpb.NotifyMessage{
 			GateAddr:  gateAddr,
@@ -464,10 +750,13 @@ func (h *LocalHandler) remoteProcess(s *session.Session, msg *message.Message, n
 				Network: s.RemoteAddr().Network(),
 				Addr:    s.RemoteAddr().String(),
 			},
+			TraceParent: traceParent,
 		}
-		_, err = client.HandleNotify(context.Background(), request)
+		_, err = client.HandleNotify(ctx, request)
 	}
+	metrics.ForwardDuration.Observe(time.Since(start).Seconds(), remoteAddr)
 	if err != nil {
+		span.SetError(err)
 		log.Errorf("Process remote message (%d:%s) error: %+v",
 			msg.ID, msg.Route, err)
 	}
@@ -485,48 +774,55 @@ func (h *LocalHandler) processMessage(s *session.Session, msg *message.Message,
 		return
 	}
 
+	ctx, span := h.startReceive(context.Background(), s, msg)
+	defer span.End()
+
 	handler, found := h.localHandlers[msg.Route]
 	if !found {
-		h.remoteProcess(s, msg, noCopy)
+		h.remoteProcess(ctx, s, msg, noCopy)
 	} else {
-		h.localProcess(handler, lastMid, s, msg)
+		h.localProcess(ctx, handler, lastMid, s, msg)
 	}
 }
 
-func (h *LocalHandler) localProcess(handler *component.Handler, lastMid uint64, s *session.Session, msg *message.Message) {
-	if pipe := h.pipeline; pipe != nil {
-		err := pipe.Inbound().Process(s, msg)
-		if err != nil {
-			log.Errorln("Pipeline process failed: " + err.Error())
-			return
-		}
+// startReceive starts the span of receiving a message
+func (h *LocalHandler) startReceive(ctx context.Context, s *session.Session, msg *message.Message) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "nano.receive", tracing.KindServer)
+	span.SetAttribute("nano.route", msg.Route)
+	span.SetAttribute("nano.type", msg.Type.String())
+	span.SetAttribute("nano.session_id", s.ID())
+	span.SetAttribute("nano.uid", s.UID())
+	return ctx, span
+}
+
+func (h *LocalHandler) localProcess(ctx context.Context, handler *component.Handler, lastMid uint64, s *session.Session, msg *message.Message) {
+	index := strings.LastIndex(msg.Route, ".")
+	if index < 0 {
+		log.Errorf("nano/handler: invalid route %s", msg.Route)
+		return
 	}
 
-	var payload = msg.Data
-	var data interface{}
-	if handler.IsRawArg {
-		data = payload
-	} else {
-		data = reflect.New(handler.Type.Elem()).Interface()
-		err := env.Serializer.Unmarshal(payload, data)
-		if err != nil {
-			log.Errorf("Deserialize to %T failed: %+v (%v)", data, err, payload)
-			return
-		}
+	// A message can be dispatch to global thread or a user customized thread
+	serviceName := msg.Route[:index]
+	service, found := h.localServices[serviceName]
+	if !found {
+		log.Errorf("Service not found: %+v", serviceName)
+		return
 	}
 
-	if env.Debug {
-		switch d := data.(type) {
-		case []byte:
-			log.Infof("Type=%s, Route=%s, ID=%d, Version=%s, UID=%d, Mid=%d, Data=%dbytes",
-				msg.Type.String(), msg.Route, s.ID(), s.Version(), s.UID(), msg.ID, len(d))
-		default:
-			log.Infof("Type=%s, Route=%s, ID=%d, Version=%s, UID=%d, Mid=%d, Data=%+v",
-				msg.Type.String(), msg.Route, s.ID(), s.Version(), s.UID(), msg.ID, data)
-		}
+	// the payload is deserialized before scheduled, which is passed to the
+	// schedule func of service, errors are returned after inbound middlewares
+	// which may reject the message or replace the data, and messages are
+	// dropped early if there's no pipeline
+	data, errData := h.deserialize(handler, msg.Data)
+	if errData != nil && h.pipeline == nil {
+		log.Errorf("nano/handler: %s %v", msg.Route, errData)
+		return
 	}
+	payload := msg.Data
 
-	args := []reflect.Value{handler.Receiver, reflect.ValueOf(s), reflect.ValueOf(data)}
+	// The inbound pipeline runs in the same thread as the handler, so that
+	// middlewares can run code after the handler returns, see Pipeline.Inbound
 	task := func() {
 		if lastMid > 0 {
 			switch v := s.NetworkEntity().(type) {
@@ -537,26 +833,85 @@ func (h *LocalHandler) localProcess(handler *component.Handler, lastMid uint64,
 			}
 		}
 
-		result := handler.Method.Func.Call(args)
-		if len(result) > 0 {
-			if err := result[0].Interface(); err != nil {
-				log.Errorf("nano/hanlder: handler %s error: %+v", msg.Route, err)
+		call := func(s *session.Session, msg *message.Message) error {
+			_, span := tracing.Start(ctx, "nano.handler", tracing.KindInternal)
+			span.SetAttribute("nano.route", msg.Route)
+			// pushes and responses sent by the handler are linked to the span
+			if v, ok := s.NetworkEntity().(*acceptor); ok {
+				defer v.traceCall(msg.ID, span.Context())()
+			}
+			data, err := data, errData
+			if !sameData(msg.Data, payload) {
+				// the data is replaced by inbound middlewares
+				data, err = h.deserialize(handler, msg.Data)
+			}
+			if err == nil {
+				err = h.call(handler, s, msg, data)
 			}
+			span.SetError(err)
+			span.End()
+			return err
 		}
-	}
 
-	index := strings.LastIndex(msg.Route, ".")
-	if index < 0 {
-		log.Errorf("nano/handler: invalid route %s", msg.Route)
-		return
-	}
+		var err error
+		if pipe := h.pipeline; pipe != nil {
+			var span *tracing.Span
+			ctx, span = tracing.Start(ctx, "nano.pipeline", tracing.KindInternal)
+			err = pipe.Inbound().Handle(s, msg, call)
+			span.SetError(err)
+			span.End()
+		} else {
+			err = call(s, msg)
+		}
+		if err == nil {
+			return
+		}
 
-	// A message can be dispatch to global thread or a user customized thread
-	serviceName := msg.Route[:index]
-	service, found := h.localServices[serviceName]
-	if !found {
-		log.Errorf("Service not found: %+v", serviceName)
+		if e, ok := err.(*pipeline.Error); ok && msg.Type == message.Request {
+			if err := s.ResponseMid(msg.ID, msg.Route, e); err != nil {
+				log.Errorf("nano/handler: response %s error: %+v", msg.Route, err)
+			}
+			return
+		}
+		log.Errorf("nano/hanlder: handler %s error: %+v", msg.Route, err)
 	}
 
 	service.Schedule(s, data, task)
 }
+
+// deserialize deserializes the payload to the argument type of handler
+func (h *LocalHandler) deserialize(handler *component.Handler, payload []byte) (interface{}, error) {
+	if handler.IsRawArg {
+		return payload, nil
+	}
+	serializer := handler.Serializer
+	if serializer == nil {
+		serializer = env.Serializer
+	}
+	data := reflect.New(handler.Type.Elem()).Interface()
+	if err := serializer.Unmarshal(payload, data); err != nil {
+		return nil, fmt.Errorf("deserialize to %T failed: %v (%v)", data, err, payload)
+	}
+	return data, nil
+}
+
+// sameData reports whether a and b are the same slice
+func sameData(a, b []byte) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// call calls the handler with the deserialized data
+func (h *LocalHandler) call(handler *component.Handler, s *session.Session, msg *message.Message, data interface{}) error {
+	debugMessage(s, msg.Type, msg.Route, msg.ID, data)
+
+	args := []reflect.Value{handler.Receiver, reflect.ValueOf(s), reflect.ValueOf(data)}
+	start := time.Now()
+	result := handler.Method.Func.Call(args)
+	metrics.HandlerDuration.Observe(time.Since(start).Seconds(), msg.Route)
+	if len(result) > 0 {
+		if err, ok := result[0].Interface().(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aura-studio/nano/cluster/clusterpb"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/mock"
+	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/replay"
+	"github.com/aura-studio/nano/scheduler"
+	"github.com/aura-studio/nano/serialize/json"
+	"github.com/aura-studio/nano/session"
+)
+
+type serializerRequest struct {
+	Content string `json:"content"`
+}
+
+type SerializerComponent struct {
+	component.Base
+	contents []string
+}
+
+func (c *SerializerComponent) Echo(s *session.Session, req *serializerRequest) error {
+	c.contents = append(c.contents, req.Content)
+	return nil
+}
+
+func (c *SerializerComponent) Raw(s *session.Session, data []byte) error {
+	c.contents = append(c.contents, string(data))
+	return nil
+}
+
+type unregisteredSerializer struct{ json.Serializer }
+
+func TestHandlerSerializer(t *testing.T) {
+	comp := &SerializerComponent{}
+	h := NewHandler()
+	var scheduled []interface{}
+	sched := func(_ *session.Session, v interface{}, task scheduler.Task) {
+		scheduled = append(scheduled, v)
+		task()
+	}
+	err := h.Register(comp, []component.Option{
+		component.WithName("HandlerSerializer"),
+		component.WithScheduleFunc(sched),
+		component.WithHandlerSerializer(json.NewSerializer(), (*SerializerComponent).Echo),
+		component.WithPush("onHandlerSerializer", &serializerRequest{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range h.LocalDictionary() {
+		if item.Route == "HandlerSerializer.Echo" && item.Serializer != uint32(message.JSON) {
+			t.Fatalf("expect serializer: %d, got: %d", message.JSON, item.Serializer)
+		}
+	}
+	if _, ok := message.ReadSerializers()["HandlerSerializer.Echo"].(*json.Serializer); !ok {
+		t.Fatalf("expect json serializer for route")
+	}
+	if _, ok := message.ReadSerializers()["onHandlerSerializer"]; ok {
+		t.Fatalf("push route is serialized by the component without serializer")
+	}
+
+	handler, err := h.RouteHandler("HandlerSerializer.Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := session.New(mock.NewNetworkEntity(), 1)
+	msg := &message.Message{Type: message.Notify, Route: "HandlerSerializer.Echo", Data: []byte(`{"content":"hello"}`)}
+	h.localProcess(context.Background(), handler, 0, s, msg)
+	if len(comp.contents) != 1 || comp.contents[0] != "hello" {
+		t.Fatalf("unexpected contents: %v", comp.contents)
+	}
+	// the schedule func receives the deserialized payload
+	if req, ok := scheduled[0].(*serializerRequest); !ok || req.Content != "hello" {
+		t.Fatalf("unexpected scheduled payload: %#v", scheduled[0])
+	}
+
+	err = NewHandler().Register(&SerializerComponent{}, []component.Option{
+		component.WithName("UnregisteredSerializer"),
+		component.WithSerializer(&unregisteredSerializer{}),
+	})
+	if err == nil {
+		t.Fatal("expect error while registering with unregistered serializer")
+	}
+}
+
+func TestHandlerPipeline(t *testing.T) {
+	comp := &SerializerComponent{}
+	h := NewHandler()
+	sched := func(_ *session.Session, _ interface{}, task scheduler.Task) { task() }
+	err := h.Register(comp, []component.Option{
+		component.WithName("HandlerPipeline"),
+		component.WithScheduleFunc(sched),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trace []string
+	h.pipeline = pipeline.New()
+	h.pipeline.Inbound().Use("trace", "HandlerPipeline.*", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		trace = append(trace, "before")
+		err := next(s, msg)
+		trace = append(trace, "after")
+		return err
+	})
+	h.pipeline.Inbound().Use("deny", "*.Echo", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		return pipeline.NewError(403, "forbidden")
+	})
+
+	entity := mock.NewNetworkEntity()
+	s := session.New(entity, 1)
+	handler, err := h.RouteHandler("HandlerPipeline.Raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.localProcess(context.Background(), handler, 0, s, &message.Message{Type: message.Notify, Route: "HandlerPipeline.Raw", Data: []byte("raw")})
+	if len(comp.contents) != 1 || len(trace) != 2 || trace[0] != "before" || trace[1] != "after" {
+		t.Fatalf("unexpected contents: %v, trace: %v", comp.contents, trace)
+	}
+
+	handler, err = h.RouteHandler("HandlerPipeline.Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.localProcess(context.Background(), handler, 2, s, &message.Message{Type: message.Request, ID: 2, Route: "HandlerPipeline.Echo", Data: []byte(`{}`)})
+	if len(comp.contents) != 1 {
+		t.Fatalf("unexpected contents: %v", comp.contents)
+	}
+	if e, ok := entity.FindResponseByMID(2).(*pipeline.Error); !ok || e.Code != 403 {
+		t.Fatalf("unexpected response: %v", entity.FindResponseByMID(2))
+	}
+}
+
+func TestHandlerInboundScheduled(t *testing.T) {
+	comp := &SerializerComponent{}
+	h := NewHandler()
+	var tasks []scheduler.Task
+	sched := func(_ *session.Session, _ interface{}, task scheduler.Task) { tasks = append(tasks, task) }
+	err := h.Register(comp, []component.Option{
+		component.WithName("HandlerInbound"),
+		component.WithScheduleFunc(sched),
+		component.WithSerializer(json.NewSerializer()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := h.RouteHandler("HandlerInbound.Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := session.New(mock.NewNetworkEntity(), 1)
+
+	// messages failed to deserialize are dropped before scheduled without
+	// pipeline
+	h.localProcess(context.Background(), handler, 0, s, &message.Message{Type: message.Notify, Route: "HandlerInbound.Echo", Data: []byte("{")})
+	if len(tasks) != 0 {
+		t.Fatal("invalid message is scheduled")
+	}
+
+	// inbound middlewares run in the scheduled task
+	inbound := 0
+	h.pipeline = pipeline.New()
+	h.pipeline.Inbound().Use("count", "", func(s *session.Session, msg *message.Message, next pipeline.Handler) error {
+		inbound++
+		return next(s, msg)
+	})
+	h.localProcess(context.Background(), handler, 0, s, &message.Message{Type: message.Notify, Route: "HandlerInbound.Echo", Data: []byte(`{"content":"hello"}`)})
+	if inbound != 0 || len(tasks) != 1 {
+		t.Fatalf("inbound middleware runs before scheduled: %d", inbound)
+	}
+	tasks[0]()
+	if inbound != 1 || len(comp.contents) != 1 {
+		t.Fatalf("unexpected inbound: %d, contents: %v", inbound, comp.contents)
+	}
+}
+
+func TestHandlerVersionPolicy(t *testing.T) {
+	h := NewHandler()
+	h.addMember(&clusterpb.MemberInfo{ServiceAddr: "stable", Services: []string{"Room"}})
+	h.addMember(&clusterpb.MemberInfo{ServiceAddr: "canary", Version: "v2-2", Services: []string{"Room"}})
+
+	route := func(uid, sid int64, shortVer uint32) string {
+		s := session.New(mock.NewNetworkEntity(), sid)
+		if uid != 0 {
+			s.BindUID(uid)
+		}
+		_, members := h.findMembers(s, "Room", shortVer)
+		if len(members) != 1 {
+			t.Fatalf("unexpected members: %v", members)
+		}
+		return members[0].ServiceAddr
+	}
+
+	if addr := route(7, 1, 0); addr != "stable" {
+		t.Fatalf("routed to %s without policies", addr)
+	}
+	if addr := route(7, 1, message.ShortVersion("v2-2")); addr != "canary" {
+		t.Fatalf("versioned session routed to %s", addr)
+	}
+
+	if err := h.setVersionPolicies([]VersionPolicy{{Version: "v2-2", UIDs: []int64{7}}}); err != nil {
+		t.Fatal(err)
+	}
+	if addr := route(7, 1, 0); addr != "canary" {
+		t.Fatalf("allowed uid routed to %s", addr)
+	}
+	if addr := route(8, 2, 0); addr != "stable" {
+		t.Fatalf("uid not allowed routed to %s", addr)
+	}
+
+	if err := h.setVersionPolicies([]VersionPolicy{{Version: "v2-2", Percent: 30}}); err != nil {
+		t.Fatal(err)
+	}
+	canary := 0
+	for uid := int64(1); uid <= 1000; uid++ {
+		if route(uid, uid, 0) == "canary" {
+			canary++
+		}
+	}
+	if canary < 250 || canary > 350 {
+		t.Fatalf("%d of 1000 sessions routed to canary", canary)
+	}
+
+	// policies of versions without members are ignored
+	if err := h.setVersionPolicies([]VersionPolicy{{Version: "v3-3", Percent: 100}}); err != nil {
+		t.Fatal(err)
+	}
+	if addr := route(7, 1, 0); addr != "stable" {
+		t.Fatalf("routed to %s by policy without members", addr)
+	}
+
+	for _, policies := range [][]VersionPolicy{
+		{{Percent: 10}},
+		{{Version: "v2-2", Percent: 101}},
+		{{Version: "v2-2", Percent: 60}, {Version: "v3-3", Percent: 60}},
+	} {
+		if err := h.setVersionPolicies(policies); err != ErrInvalidVersionPolicy {
+			t.Fatalf("policies %v are accepted", policies)
+		}
+	}
+}
+
+func TestHandlerReplay(t *testing.T) {
+	h := NewHandler()
+	config := &replay.Config{}
+	a := &agent{session: session.New(mock.NewNetworkEntity(), 1)}
+	check := func(typ message.Type, id uint64, signID uint64) error {
+		data := []byte("data")
+		if secret := config.SecretOf(a.session); len(secret) > 0 {
+			data = replay.Sign(secret, typ, signID, "Room.Chat", data)
+		}
+		msg := &message.Message{Type: typ, ID: id, Route: "Room.Chat", Data: data}
+		if err := h.checkReplay(config, a, msg); err != nil {
+			return err
+		}
+		if string(msg.Data) != "data" || (typ == message.Notify && msg.ID != 0) {
+			t.Fatalf("unexpected message after checked: %v %d", msg, msg.ID)
+		}
+		return nil
+	}
+
+	if err := check(message.Request, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(message.Request, 1, 1); err != replay.ErrReplayed {
+		t.Fatalf("replayed request is accepted: %v", err)
+	}
+	// unsigned notifies are not numbered
+	for i := 0; i < 2; i++ {
+		if err := check(message.Notify, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replay.SetSecret(a.session, []byte("secret"))
+	if err := check(message.Notify, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(message.Notify, 2, 2); err != replay.ErrReplayed {
+		t.Fatalf("replayed notify is accepted: %v", err)
+	}
+	if err := check(message.Notify, 3, 2); err != replay.ErrInvalidSignature {
+		t.Fatalf("notify with changed id is accepted: %v", err)
+	}
+	if err := check(message.Request, 3, 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandlerAnonymousRoutes(t *testing.T) {
+	h := NewHandler()
+	member := func(addr, version string, anonymous bool) *clusterpb.MemberInfo {
+		return &clusterpb.MemberInfo{
+			ServiceAddr: addr,
+			Version:     version,
+			Services:    []string{"Account"},
+			Dictionary:  []*clusterpb.DictionaryItem{{Route: "Account.Guest", Anonymous: anonymous}},
+		}
+	}
+
+	h.addMember(member("v1", "", true))
+	if !h.isAnonymous("Account.Guest") {
+		t.Fatal("route is not anonymous")
+	}
+	// a member of another version does not overwrite the flag
+	h.addMember(member("v2", "v2-2", false))
+	if h.isAnonymous("Account.Guest") {
+		t.Fatal("route is anonymous while a member is not")
+	}
+	h.delMember("v2")
+	if !h.isAnonymous("Account.Guest") {
+		t.Fatal("route is not anonymous after the member is deleted")
+	}
+	h.delMember("v1")
+	if h.isAnonymous("Account.Guest") {
+		t.Fatal("route is still anonymous without members")
+	}
+}
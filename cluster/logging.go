@@ -0,0 +1,39 @@
+package cluster
+
+import (
+	"github.com/aura-studio/nano/env"
+	"github.com/aura-studio/nano/log"
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/session"
+)
+
+// debugMessage writes the debug log of a message of session, which is written
+// in debug mode or sampled by log.SetSampleRate, the debug logs are written at
+// the info level as former versions, so that they are not filtered out by the
+// level of logger in debug mode
+func debugMessage(s *session.Session, typ message.Type, route string, mid uint64, v interface{}) {
+	if !env.Debug && !log.Sampled() {
+		return
+	}
+	// check the base logger before binding fields of session
+	if !log.Structured().Enabled(log.LevelInfo) {
+		return
+	}
+
+	kv := []interface{}{"type", typ.String(), "route", route, "mid", mid}
+	switch d := v.(type) {
+	case []byte:
+		kv = append(kv, "bytes", len(d))
+	default:
+		kv = append(kv, "data", d)
+	}
+	log.LogDepth(s.Logger(), 1, log.LevelInfo, "message", kv...)
+}
+
+// debugSession writes the debug log of session in debug mode at the info level
+func debugSession(s *session.Session, msg string, kv ...interface{}) {
+	if !env.Debug {
+		return
+	}
+	log.LogDepth(s.Logger(), 1, log.LevelInfo, msg, kv...)
+}
@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"bytes"
+	stdlog "log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aura-studio/nano/env"
+	"github.com/aura-studio/nano/log"
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/session"
+)
+
+// disabledLogger counts fields bound while debug logs are disabled
+type disabledLogger struct{ bound int }
+
+func (l *disabledLogger) Enabled(log.Level) bool                   { return false }
+func (l *disabledLogger) Log(log.Level, string, ...interface{})    {}
+func (l *disabledLogger) With(...interface{}) log.StructuredLogger { l.bound++; return l }
+
+// levelLogger records the levels of logs
+type levelLogger struct{ levels []log.Level }
+
+func (l *levelLogger) Enabled(log.Level) bool { return true }
+func (l *levelLogger) Log(level log.Level, _ string, _ ...interface{}) {
+	l.levels = append(l.levels, level)
+}
+func (l *levelLogger) With(...interface{}) log.StructuredLogger { return l }
+
+func TestDebugLevel(t *testing.T) {
+	logger := &levelLogger{}
+	defer log.SetStructuredLogger(log.Structured())
+	log.SetStructuredLogger(logger)
+	defer func(debug bool) { env.Debug = debug }(env.Debug)
+	env.Debug = true
+
+	s := session.New(nil, 1)
+	debugMessage(s, message.Request, "Room.Join", 1, []byte("data"))
+	debugSession(s, "session established")
+	if len(logger.levels) != 2 || logger.levels[0] != log.LevelInfo || logger.levels[1] != log.LevelInfo {
+		t.Fatalf("debug logs are not written at the info level: %v", logger.levels)
+	}
+}
+
+func TestDebugCaller(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetLogger(stdlog.New(buf, "", stdlog.Lshortfile))
+	defer log.SetLogger(stdlog.New(os.Stderr, "", stdlog.LstdFlags|stdlog.Lshortfile))
+	defer func(debug bool) { env.Debug = debug }(env.Debug)
+	env.Debug = true
+
+	debugSession(session.New(nil, 1), "session established")
+	if got := buf.String(); !strings.HasPrefix(got, "logging_test.go:") {
+		t.Fatalf("caller is not reported: %q", got)
+	}
+}
+
+func TestDebugMessage(t *testing.T) {
+	logger := &disabledLogger{}
+	defer log.SetStructuredLogger(log.Structured())
+	log.SetStructuredLogger(logger)
+	defer log.SetSampleRate(log.SampleRate())
+	log.SetSampleRate(1)
+
+	debugMessage(session.New(nil, 1), message.Request, "Room.Join", 1, []byte("data"))
+	if logger.bound != 0 {
+		t.Fatal("fields are bound while debug logs are disabled")
+	}
+}
@@ -27,16 +27,23 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/aura-studio/nano/admission"
+	"github.com/aura-studio/nano/auth"
 	"github.com/aura-studio/nano/cluster/clusterpb"
 	"github.com/aura-studio/nano/component"
 	"github.com/aura-studio/nano/env"
 	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/metrics"
 	"github.com/aura-studio/nano/persistence"
 	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/ratelimit"
+	"github.com/aura-studio/nano/replay"
 	"github.com/aura-studio/nano/session"
+	"github.com/aura-studio/nano/tracing"
 	"github.com/aura-studio/nano/upgrader"
 	"github.com/gorilla/mux"
 	"google.golang.org/grpc"
@@ -59,6 +66,48 @@ type Options struct {
 	TSLCertificate string
 	TSLKey         string
 	Logger         log.Logger
+	// StructuredLogger overrides the default structured logger, which writes
+	// by Logger if nil
+	StructuredLogger log.StructuredLogger
+	// LogSampleRate is the rate of per-message debug logs to be written, the
+	// rate set by log.SetSampleRate is kept if zero
+	LogSampleRate float64
+	TraceExporter tracing.Exporter
+
+	// DrainTimeout is the longest time to wait for sessions to finish when the
+	// node shuts down gracefully, the node shuts down immediately if zero
+	DrainTimeout time.Duration
+	// DrainRoute and DrainMessage are pushed to clients of the gate when it
+	// starts draining, nothing is pushed if the route is empty
+	DrainRoute   string
+	DrainMessage interface{}
+
+	// VersionPolicies route unversioned sessions to members of versions, which
+	// can be switched at runtime by Node.SetVersionPolicies
+	VersionPolicies []VersionPolicy
+
+	// RateLimits limits messages from clients by rules before dispatching
+	RateLimits []ratelimit.Rule
+
+	// Admission controls client connections accepted by the gate
+	Admission *admission.Config
+
+	// Auth authenticates clients on the gate and rejects messages of routes
+	// not anonymous before authenticated
+	Auth *auth.Config
+
+	// Replay rejects replayed requests and messages with invalid signatures
+	// on the gate
+	Replay *replay.Config
+
+	// AdminAuth authorizes requests of admin API operations, such as draining
+	// members and kicking sessions, which are disabled if nil
+	AdminAuth func(r *http.Request) bool
+
+	// ServiceListener and ServiceDialer override the network of service
+	// addresses, such as in-memory network in tests, TCP is used if nil
+	ServiceListener func(addr string) (net.Listener, error)
+	ServiceDialer   func(ctx context.Context, addr string) (net.Conn, error)
 }
 
 // Node represents a node in nano cluster, which will contains a group of services.
@@ -76,6 +125,13 @@ type Node struct {
 
 	mu       sync.RWMutex
 	sessions map[int64]*session.Session
+
+	draining  int32
+	shutdown  int32
+	die       chan struct{} // closed when shut down, which closes client agents
+	listener  net.Listener  // client listener
+	admission *admission.Controller
+	limiter   *ratelimit.Limiter
 }
 
 // Startup bootstraps a start up.
@@ -83,9 +139,30 @@ func (n *Node) Startup() error {
 	if n.ServiceAddr == "" {
 		return errors.New("service address cannot be empty in master node")
 	}
+	if n.LogSampleRate > 0 {
+		log.SetSampleRate(n.LogSampleRate)
+	}
 	n.sessions = map[int64]*session.Session{}
+	n.die = make(chan struct{})
 	n.cluster = newCluster(n)
+	if n.Admission != nil {
+		controller, err := admission.New(*n.Admission)
+		if err != nil {
+			return err
+		}
+		n.admission = controller
+	}
+	if len(n.RateLimits) > 0 {
+		limiter, err := ratelimit.New(n.RateLimits...)
+		if err != nil {
+			return err
+		}
+		n.limiter = limiter
+	}
 	n.handler = newHandler(n)
+	if err := n.handler.setVersionPolicies(n.VersionPolicies); err != nil {
+		return err
+	}
 	n.conventioner = newConventioner(n)
 	components := n.Components.List()
 	for _, c := range components {
@@ -137,14 +214,20 @@ func (n *Node) initNode() error {
 		return nil
 	}
 
-	listener, err := net.Listen("tcp", n.ServiceAddr)
+	var listener net.Listener
+	var err error
+	if n.ServiceListener != nil {
+		listener, err = n.ServiceListener(n.ServiceAddr)
+	} else {
+		listener, err = net.Listen("tcp", n.ServiceAddr)
+	}
 	if err != nil {
 		return err
 	}
 
 	// Initialize the gRPC server and register service
 	n.server = grpc.NewServer()
-	n.rpcClient = newRPCClient()
+	n.rpcClient = newRPCClient(n.ServiceDialer)
 	clusterpb.RegisterMemberServer(n.server, n)
 
 	go func() {
@@ -212,6 +295,11 @@ func (n *Node) initNode() error {
 // Shutdown all components registered by application, that
 // call by reverse order against register
 func (n *Node) Shutdown() {
+	if !atomic.CompareAndSwapInt32(&n.shutdown, 0, 1) {
+		return
+	}
+	close(n.die)
+
 	// reverse call `BeforeShutdown` hooks
 	components := n.Components.List()
 	length := len(components)
@@ -254,28 +342,125 @@ func (n *Node) listenAndServe() {
 		log.Fatal(err.Error())
 	}
 
+	n.mu.Lock()
+	n.listener = listener
+	n.mu.Unlock()
+
 	defer listener.Close()
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if n.isDraining() {
+				return
+			}
 			log.Errorln(err.Error())
 			continue
 		}
 
-		go n.handler.handle(conn)
+		go n.ServeConn(conn)
+	}
+}
+
+// ServeConn serves a client connection accepted by other listeners, such as
+// in-memory pipes, it blocks until the connection is closed
+func (n *Node) ServeConn(conn net.Conn) {
+	n.serveConn(conn, 0, nil)
+}
+
+// serveConn serves a client connection, the session is bound to uid if it is
+// authenticated by handshake
+func (n *Node) serveConn(conn net.Conn, uid int64, release func()) {
+	if release != nil {
+		defer release()
+	}
+	if n.isDraining() {
+		conn.Close()
+		return
+	}
+	if n.admission != nil {
+		var err error
+		if release == nil {
+			release, err = n.admission.Admit(conn)
+			if err == nil {
+				defer release()
+			}
+		} else {
+			err = n.admission.Accept(conn)
+		}
+		if err != nil {
+			n.reject(conn.RemoteAddr(), err)
+			conn.Close()
+			return
+		}
+	}
+	n.handler.handle(conn, uid)
+}
+
+// reject records the connection from addr rejected by admission
+func (n *Node) reject(addr net.Addr, err error) {
+	metrics.Rejected.Inc(admission.Reason(err))
+	if env.Debug {
+		log.With("remote", addr).Log(log.LevelDebug, "connection rejected", "error", err)
 	}
 }
 
+// requestAddr is the remote address of a HTTP request
+type requestAddr string
+
+func (a requestAddr) Network() string { return "tcp" }
+func (a requestAddr) String() string  { return string(a) }
+
 func (n *Node) listenAndServeHttp() {
 	router := mux.NewRouter()
 	router.HandleFunc("/{route:[A-Za-z\\.]*}", func(w http.ResponseWriter, r *http.Request) {
+		opens := true
+		if o, ok := n.HttpUpgrader.(upgrader.Opener); ok {
+			opens = o.Opens(r)
+		}
+
+		// admit before authenticating and upgrading, requests served by
+		// existing connections are only checked by allow and deny lists
+		var release func()
+		if n.admission != nil {
+			var err error
+			if opens {
+				release, err = n.admission.AdmitAddr(requestAddr(r.RemoteAddr))
+			} else {
+				err = n.admission.CheckAddr(requestAddr(r.RemoteAddr))
+			}
+			if err != nil {
+				n.reject(requestAddr(r.RemoteAddr), err)
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		var uid int64
+		if opens && n.Auth != nil && n.Auth.Handshake != nil {
+			var err error
+			if uid, err = n.Auth.Handshake(r); err != nil {
+				if release != nil {
+					release()
+				}
+				http.Error(w, auth.ErrUnauthenticated.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
 		params := mux.Vars(r)
 		conn, err := n.HttpUpgrader.Upgrade(w, r, params)
-		if err != nil {
-			log.Errorf("Upgrade failure, URI=%s, Error=%s", r.RequestURI, err.Error())
+		if err != nil || conn == nil {
+			if release != nil {
+				release()
+			}
+			if err != nil {
+				log.Errorf("Upgrade failure, URI=%s, Error=%s", r.RequestURI, err.Error())
+			}
+			// otherwise the request is served by an existing connection
+			return
 		}
 
-		go n.handler.handle(conn)
+		go n.serveConn(conn, uid, release)
 	})
 	http.Handle("/", router)
 
@@ -297,8 +482,14 @@ func (n *Node) listenAndServeHttp() {
 	}
 }
 
+// ListenAndServeDebug serves the default http mux, metrics on "/metrics" and
+// the admin API on "/admin/"
 func (n *Node) ListenAndServeDebug() {
-	if err := http.ListenAndServe(n.DebugAddr, nil); err != nil {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default)
+	mux.Handle("/admin/", n.AdminHandler())
+	mux.Handle("/", http.DefaultServeMux)
+	if err := http.ListenAndServe(n.DebugAddr, mux); err != nil {
 		log.Fatal(err.Error())
 	}
 }
@@ -334,14 +525,12 @@ func (n *Node) findOrCreateSession(sid int64, gateAddr string, uid int64, shortV
 		if err != nil {
 			return nil, err
 		}
-		serializers := message.ReadSerializers()
 		ac := &acceptor{
-			sid:         sid,
-			gateClient:  clusterpb.NewMemberClient(conns.Get()),
-			rpcHandler:  n.handler.processMessage,
-			gateAddr:    gateAddr,
-			serializers: serializers,
-			remoteAddr:  remoteAddr,
+			sid:        sid,
+			gateClient: clusterpb.NewMemberClient(conns.Get()),
+			rpcHandler: n.handler.processMessage,
+			gateAddr:   gateAddr,
+			remoteAddr: remoteAddr,
 		}
 		s = session.New(ac, sid)
 
@@ -358,12 +547,15 @@ func (n *Node) findOrCreateSession(sid int64, gateAddr string, uid int64, shortV
 		n.mu.Unlock()
 
 		session.Inited(s)
+	} else if uid != 0 && s.UID() != uid {
+		// the session is authenticated by the gate after created
+		s.BindUID(uid)
 	}
 	return s, nil
 }
 
 // HandleRequest is called by grpc `HandleRequest`
-func (n *Node) HandleRequest(_ context.Context, req *clusterpb.RequestMessage) (*clusterpb.MemberHandleResponse, error) {
+func (n *Node) HandleRequest(ctx context.Context, req *clusterpb.RequestMessage) (*clusterpb.MemberHandleResponse, error) {
 	handler, found := n.handler.localHandlers[req.Route]
 	if !found {
 		return nil, fmt.Errorf("service not found in current node: %v", req.Route)
@@ -380,12 +572,15 @@ func (n *Node) HandleRequest(_ context.Context, req *clusterpb.RequestMessage) (
 		Route:    req.Route,
 		Data:     req.Data,
 	}
-	n.handler.localProcess(handler, req.ID, s, msg)
+	metrics.Messages.Inc(metrics.In, msg.Type.String(), msg.Route)
+	ctx, span := n.handler.startReceive(traceContext(ctx, req.TraceParent), s, msg)
+	defer span.End()
+	n.handler.localProcess(ctx, handler, req.ID, s, msg)
 	return &clusterpb.MemberHandleResponse{}, nil
 }
 
 // HandleNotify is called by grpc `HandleNotify`
-func (n *Node) HandleNotify(_ context.Context, req *clusterpb.NotifyMessage) (*clusterpb.MemberHandleResponse, error) {
+func (n *Node) HandleNotify(ctx context.Context, req *clusterpb.NotifyMessage) (*clusterpb.MemberHandleResponse, error) {
 	handler, found := n.handler.localHandlers[req.Route]
 	if !found {
 		return nil, fmt.Errorf("service not found in current node: %v", req.Route)
@@ -402,26 +597,73 @@ func (n *Node) HandleNotify(_ context.Context, req *clusterpb.NotifyMessage) (*c
 		Route:    req.Route,
 		Data:     req.Data,
 	}
-	n.handler.localProcess(handler, req.ID, s, msg)
+	metrics.Messages.Inc(metrics.In, msg.Type.String(), msg.Route)
+	ctx, span := n.handler.startReceive(traceContext(ctx, req.TraceParent), s, msg)
+	defer span.End()
+	n.handler.localProcess(ctx, handler, req.ID, s, msg)
 	return &clusterpb.MemberHandleResponse{}, nil
 }
 
 // HandlePush is called by grpc `HandlePush`
-func (n *Node) HandlePush(_ context.Context, req *clusterpb.PushMessage) (*clusterpb.MemberHandleResponse, error) {
+func (n *Node) HandlePush(ctx context.Context, req *clusterpb.PushMessage) (*clusterpb.MemberHandleResponse, error) {
 	s := n.findSession(req.SessionID)
 	if s == nil {
 		return &clusterpb.MemberHandleResponse{}, fmt.Errorf("session not found: %v", req.SessionID)
 	}
-	return &clusterpb.MemberHandleResponse{}, s.Push(req.Route, req.Data)
+	msg := &message.Message{
+		Type:  message.Push,
+		Route: req.Route,
+		Data:  req.Data,
+	}
+	return &clusterpb.MemberHandleResponse{}, n.handleBackend(traceContext(ctx, req.TraceParent), s, msg, func(s *session.Session, msg *message.Message) error {
+		return s.PushPriority(msg.Route, msg.Data, message.Priority(req.Priority))
+	})
 }
 
 // HandleResponse is called by grpc `HandleResponse`
-func (n *Node) HandleResponse(_ context.Context, req *clusterpb.ResponseMessage) (*clusterpb.MemberHandleResponse, error) {
+func (n *Node) HandleResponse(ctx context.Context, req *clusterpb.ResponseMessage) (*clusterpb.MemberHandleResponse, error) {
 	s := n.findSession(req.SessionID)
 	if s == nil {
 		return &clusterpb.MemberHandleResponse{}, fmt.Errorf("session not found: %v", req.SessionID)
 	}
-	return &clusterpb.MemberHandleResponse{}, s.ResponseMid(req.ID, req.Route, req.Data)
+	msg := &message.Message{
+		Type:  message.Response,
+		ID:    req.ID,
+		Route: req.Route,
+		Data:  req.Data,
+		Error: req.Error,
+	}
+	return &clusterpb.MemberHandleResponse{}, n.handleBackend(traceContext(ctx, req.TraceParent), s, msg, func(s *session.Session, msg *message.Message) error {
+		if msg.Error {
+			e, err := pipeline.DecodeError(msg.Data)
+			if err != nil {
+				return err
+			}
+			return s.ResponseMid(msg.ID, msg.Route, e)
+		}
+		return s.ResponseMid(msg.ID, msg.Route, msg.Data)
+	})
+}
+
+// handleBackend sends the message from a backend to the session through the
+// backend pipeline, messages dropped by the pipeline are not reported to the
+// backend as errors
+func (n *Node) handleBackend(ctx context.Context, s *session.Session, msg *message.Message, send pipeline.Handler) (err error) {
+	// only traced if the backend is traced
+	if tracing.SpanContextFromContext(ctx).IsValid() {
+		_, span := tracing.Start(ctx, "nano.backend", tracing.KindServer)
+		span.SetAttribute("nano.route", msg.Route)
+		span.SetAttribute("nano.type", msg.Type.String())
+		defer func() {
+			span.SetError(err)
+			span.End()
+		}()
+	}
+
+	if n.Pipeline == nil {
+		return send(s, msg)
+	}
+	return n.Pipeline.Backend().Handle(s, msg, send)
 }
 
 // NewMember is called by grpc `NewMember`
@@ -462,6 +704,31 @@ func (n *Node) CloseSession(_ context.Context, req *clusterpb.CloseSessionReques
 	return &clusterpb.CloseSessionResponse{}, nil
 }
 
+// DrainMember implements the MemberServer interface
+func (n *Node) DrainMember(_ context.Context, req *clusterpb.DrainMemberRequest) (*clusterpb.DrainMemberResponse, error) {
+	n.handler.drainMember(req.ServiceAddr)
+	n.cluster.drainMember(req.ServiceAddr)
+	return &clusterpb.DrainMemberResponse{}, nil
+}
+
+// Drain stops routing new sessions to the member of address, which is done by
+// the master
+func (n *Node) Drain(addr string) error {
+	request := &clusterpb.DrainRequest{ServiceAddr: addr}
+	if n.IsMaster {
+		_, err := n.cluster.Drain(context.Background(), request)
+		return err
+	}
+
+	pool, err := n.rpcClient.getConnPool(n.AdvertiseAddr)
+	if err != nil {
+		return err
+	}
+	client := clusterpb.NewMasterClient(pool.Get())
+	_, err = client.Drain(context.Background(), request)
+	return err
+}
+
 // PerformConvention implements the MemberServer interface
 func (n *Node) PerformConvention(_ context.Context, req *clusterpb.PerformConventionRequest) (*clusterpb.PerformConventionResponse, error) {
 	if n.conventioner.acceptor != nil {
@@ -0,0 +1,34 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/aura-studio/nano/tracing"
+	"google.golang.org/grpc/metadata"
+)
+
+// traceParentKey is the gRPC metadata key of trace context
+const traceParentKey = "traceparent"
+
+// withTrace appends the trace context to the outgoing gRPC metadata, and
+// returns the traceparent carried in the cluster messages
+func withTrace(ctx context.Context, sc tracing.SpanContext) (context.Context, string) {
+	traceParent := sc.TraceParent()
+	if traceParent == "" {
+		return ctx, ""
+	}
+	return metadata.AppendToOutgoingContext(ctx, traceParentKey, traceParent), traceParent
+}
+
+// traceContext returns a context carrying the trace context received from a
+// member, the traceparent of message takes precedence over the gRPC metadata
+func traceContext(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(traceParentKey); len(values) > 0 {
+				traceParent = values[0]
+			}
+		}
+	}
+	return tracing.ContextWithTraceParent(context.Background(), traceParent)
+}
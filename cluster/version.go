@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"errors"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/aura-studio/nano/session"
+)
+
+// ErrInvalidVersionPolicy represents a version policy without version or with
+// a percent out of [0, 100]
+var ErrInvalidVersionPolicy = errors.New("invalid version policy")
+
+// VersionPolicy routes unversioned sessions, whose versions are not provided by
+// any member, to members of a version. Sessions of UIDs in the allowlist are
+// always routed to the version, and others are routed by percent, which are
+// bucketed by UID, or by session id before the UID is bound.
+type VersionPolicy struct {
+	Version string  `json:"version"`
+	Percent int     `json:"percent"`
+	UIDs    []int64 `json:"uids,omitempty"`
+}
+
+func (p *VersionPolicy) validate() error {
+	if p.Version == "" || p.Percent < 0 || p.Percent > 100 {
+		return ErrInvalidVersionPolicy
+	}
+	return nil
+}
+
+func (p *VersionPolicy) allowed(uid int64) bool {
+	if uid == 0 {
+		return false
+	}
+	for _, u := range p.UIDs {
+		if u == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket returns the canary bucket of session in [0, 100)
+func bucket(s *session.Session) int {
+	key := "sid:" + strconv.FormatInt(s.ID(), 10)
+	if uid := s.UID(); uid != 0 {
+		key = "uid:" + strconv.FormatInt(uid, 10)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+// policyVersion returns the version routed to by policies for the service, the
+// policies are checked in order and the first matched one with members is
+// taken, it should be called with the lock held
+func (h *LocalHandler) policyVersion(s *session.Session, service string) string {
+	if s == nil || len(h.policies) == 0 {
+		return ""
+	}
+
+	// allowlists have precedence over percentages
+	for _, p := range h.policies {
+		if len(h.remoteServices[service][p.Version]) > 0 && p.allowed(s.UID()) {
+			return p.Version
+		}
+	}
+
+	b := bucket(s)
+	lower := 0
+	for _, p := range h.policies {
+		upper := lower + p.Percent
+		if b >= lower && b < upper && len(h.remoteServices[service][p.Version]) > 0 {
+			return p.Version
+		}
+		lower = upper
+	}
+	return ""
+}
+
+// SetVersionPolicies replaces version policies of the node, which takes effect
+// on sessions not bound to the service yet. Policies are not propagated to other
+// gates, which should be set on each gate.
+func (n *Node) SetVersionPolicies(policies []VersionPolicy) error {
+	return n.handler.setVersionPolicies(policies)
+}
+
+func (h *LocalHandler) setVersionPolicies(policies []VersionPolicy) error {
+	total := 0
+	for i := range policies {
+		if err := policies[i].validate(); err != nil {
+			return err
+		}
+		total += policies[i].Percent
+	}
+	if total > 100 {
+		return ErrInvalidVersionPolicy
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.policies = append([]VersionPolicy(nil), policies...)
+	return nil
+}
+
+func (h *LocalHandler) versionPolicies() []VersionPolicy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]VersionPolicy{}, h.policies...)
+}
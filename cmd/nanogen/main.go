@@ -0,0 +1,74 @@
+// Copyright (c) nano Authors. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Command nanogen generates typed client stubs from the schema exported by
+// nano servers, such as:
+//
+//	//go:generate nanogen -schema schema.json -lang go -package client -out client/client.go
+//	//go:generate nanogen -schema schema.json -lang ts -out web/src/client.ts
+//
+// The schema can be exported by (*cluster.LocalHandler).Schema or
+// schema.FromComponents without running the server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/aura-studio/nano/codegen"
+	"github.com/aura-studio/nano/schema"
+)
+
+func main() {
+	var (
+		schemaPath = flag.String("schema", "schema.json", "path of the schema file")
+		lang       = flag.String("lang", "go", "language of generated code: go, ts")
+		out        = flag.String("out", "", "output file, stdout if empty")
+		pkg        = flag.String("package", "client", "package name of generated Go code")
+		client     = flag.String("client", "Client", "name of generated client type")
+	)
+	flag.Parse()
+
+	s, err := schema.Load(*schemaPath)
+	if err != nil {
+		fatal(err)
+	}
+
+	code, err := codegen.Generate(*lang, s, codegen.Options{Package: *pkg, Client: *client})
+	if err != nil {
+		fatal(err)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(code)
+	} else {
+		err = ioutil.WriteFile(*out, code, 0644)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "nanogen:", err)
+	os.Exit(1)
+}
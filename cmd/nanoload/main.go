@@ -0,0 +1,112 @@
+// Copyright (c) nano Authors. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Command nanoload generates load against nano servers, the load is described
+// by a scenario file, such as:
+//
+//	{
+//	  "addr": "127.0.0.1:3250",
+//	  "clients": 1000,
+//	  "connectRate": 200,
+//	  "duration": "1m",
+//	  "thinkTime": "100ms",
+//	  "timeout": "5s",
+//	  "serializer": "json",
+//	  "requests": [
+//	    {"route": "Room.Join", "weight": 1, "payload": {"name": "bot-{{.Client}}"}},
+//	    {"route": "Room.Message", "weight": 9, "payload": {"seq": {{.Seq}}, "text": "hello"}}
+//	  ]
+//	}
+//
+// Run with -local to start a nano server in process, which serves Echo.Echo
+// and Echo.Discard with raw payloads.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aura-studio/nano"
+	"github.com/aura-studio/nano/benchmark/load"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/session"
+)
+
+// Echo is the component served by the local server
+type Echo struct {
+	component.Base
+}
+
+// Echo responds the request payload
+func (e *Echo) Echo(s *session.Session, data []byte) error {
+	return s.Response("Echo.Echo", data)
+}
+
+// Discard drops the payload
+func (e *Echo) Discard(s *session.Session, data []byte) error {
+	return nil
+}
+
+func main() {
+	var (
+		scenarioPath = flag.String("scenario", "scenario.json", "path of the scenario file")
+		addr         = flag.String("addr", "", "server address, overrides the address of scenario")
+		local        = flag.Bool("local", false, "start a local nano server at the address")
+	)
+	flag.Parse()
+
+	s, err := load.LoadScenario(*scenarioPath)
+	if err != nil {
+		fatal(err)
+	}
+	if *addr != "" {
+		s.Addr = *addr
+	}
+
+	if *local {
+		components := &component.Components{}
+		components.Register(&Echo{})
+		go nano.Listen(s.Addr, nano.WithComponents(components))
+		<-nano.Ready()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sg := make(chan os.Signal, 1)
+		signal.Notify(sg, syscall.SIGINT, syscall.SIGTERM)
+		<-sg
+		cancel()
+	}()
+
+	report, err := load.Run(ctx, s)
+	if err != nil {
+		fatal(err)
+	}
+	report.Print(os.Stdout)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "nanoload:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,129 @@
+// Copyright (c) nano Authors. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package codegen generates typed client stubs from the schema exported by
+// nano servers, stubs are generated in Go for connector.Connector and in
+// TypeScript for web clients.
+package codegen
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/aura-studio/nano/schema"
+)
+
+const header = "Code generated by nanogen. DO NOT EDIT."
+
+// Options contains some configurations for generators
+type Options struct {
+	Package string // package name of generated Go code
+	Client  string // name of generated client type
+}
+
+func (o Options) client() string {
+	if o.Client == "" {
+		return "Client"
+	}
+	return o.Client
+}
+
+// identifier converts route into an exported identifier, such as:
+// "Room.Join" => "RoomJoin", "onJoin" => "OnJoin"
+func identifier(route string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range route {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	id := sb.String()
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "X" + id
+	}
+	return id
+}
+
+// pushIdentifier returns the subscription name of a push route, the "on"
+// prefix of route is not repeated
+func pushIdentifier(route string) string {
+	id := identifier(route)
+	if len(id) > 2 && strings.HasPrefix(id, "On") && unicode.IsUpper(rune(id[2])) {
+		return id
+	}
+	return "On" + id
+}
+
+// lowerFirst converts the first letter of s to lower case
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// handlers returns all handlers in schema by order
+func handlers(s *schema.Schema) []*schema.Handler {
+	var result []*schema.Handler
+	for _, service := range s.Services {
+		result = append(result, service.Handlers...)
+	}
+	return result
+}
+
+// pushes returns all pushes in schema by order, duplicated routes declared by
+// multiple services are only returned once
+func pushes(s *schema.Schema) []*schema.Push {
+	var result []*schema.Push
+	seen := make(map[string]bool)
+	for _, service := range s.Services {
+		for _, p := range service.Pushes {
+			if seen[p.Route] {
+				continue
+			}
+			seen[p.Route] = true
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Generate generates client stubs in specified language, "go" and "ts" are
+// supported
+func Generate(lang string, s *schema.Schema, opts Options) ([]byte, error) {
+	switch strings.ToLower(lang) {
+	case "go", "golang":
+		return GenerateGo(s, opts)
+	case "ts", "typescript":
+		return GenerateTypeScript(s, opts)
+	default:
+		return nil, fmt.Errorf("codegen: unsupported language %q", lang)
+	}
+}
@@ -0,0 +1,136 @@
+package codegen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/aura-studio/nano/codegen/testdata"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/schema"
+	"github.com/aura-studio/nano/serialize/json"
+)
+
+func newSchema(t *testing.T) *schema.Schema {
+	comps := &component.Components{}
+	comps.Register(&testdata.RoomComponent{},
+		component.WithHandlerSerializer(json.NewSerializer(), (*testdata.RoomComponent).Join),
+		component.WithHandlerResponse(&testdata.JoinResponse{}, (*testdata.RoomComponent).Join),
+		component.WithPush("onJoin", &testdata.JoinResponse{}),
+	)
+	s, err := schema.FromComponents("1.0.0", comps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// exportImporter returns an importer of the packages and their dependencies by
+// export data built by the go command
+func exportImporter(t *testing.T, fset *token.FileSet, pkgs []string) types.Importer {
+	args := append([]string{"list", "-export", "-deps", "-f", "{{.ImportPath}}={{.Export}}"}, pkgs...)
+	out, err := exec.Command("go", args...).Output()
+	if err != nil {
+		t.Fatalf("list export data: %v", err)
+	}
+	exports := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if idx := strings.Index(line, "="); idx > 0 {
+			exports[line[:idx]] = line[idx+1:]
+		}
+	}
+	return importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		export, ok := exports[path]
+		if !ok || export == "" {
+			return nil, fmt.Errorf("export data of %s not found", path)
+		}
+		return os.Open(export)
+	})
+}
+
+func TestGenerateGo(t *testing.T) {
+	code, err := GenerateGo(newSchema(t), Options{Package: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// type-check the generated code against the packages imported
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "client.go", code, 0)
+	if err != nil {
+		t.Fatalf("generated code is invalid: %v\n%s", err, code)
+	}
+	var imports []string
+	for _, spec := range file.Imports {
+		imports = append(imports, strings.Trim(spec.Path.Value, `"`))
+	}
+	config := &types.Config{Importer: exportImporter(t, fset, imports)}
+	if _, err := config.Check("client", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated code is invalid: %v\n%s", err, code)
+	}
+
+	for _, expect := range []string{
+		"// " + header,
+		`"github.com/aura-studio/nano/codegen/testdata"`,
+		`testdata1 "github.com/aura-studio/nano/benchmark/testdata"`,
+		`RouteRoomComponentJoin = "RoomComponent.Join"`,
+		"func (c *Client) RoomComponentJoin(req *testdata.JoinRequest, cb func(*testdata.JoinResponse, error)) error",
+		"c.marshal(jsonSerializer, req)",
+		"func (c *Client) RoomComponentPing(req *testdata1.Ping, cb connector.Callback) error",
+		"func (c *Client) NotifyRoomComponentRaw(req []byte) error",
+		"func (c *Client) OnJoin(cb func(*testdata.JoinResponse, error))",
+	} {
+		if !strings.Contains(string(code), expect) {
+			t.Fatalf("%q not found in generated code:\n%s", expect, code)
+		}
+	}
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	code, err := GenerateTypeScript(newSchema(t), Options{Client: "RoomClient"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expect := range []string{
+		"export interface JoinRequest {\n  name?: string;\n  roomId: number;\n}",
+		"export interface JoinResponse {\n  members: { [key: string]: number };\n}",
+		"export class RoomClient {",
+		"roomComponentJoin(req: JoinRequest): Promise<JoinResponse> {",
+		"roomComponentPing(req: Ping): Promise<unknown> {",
+		"notifyRoomComponentRaw(req: Uint8Array): void {",
+		"onJoin(callback: (payload: JoinResponse) => void): void {",
+	} {
+		if !strings.Contains(string(code), expect) {
+			t.Fatalf("%q not found in generated code:\n%s", expect, code)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	cases := map[string]string{
+		"Room.Join":   "RoomJoin",
+		"room.join":   "RoomJoin",
+		"onJoin":      "OnJoin",
+		"chat.on-msg": "ChatOnMsg",
+		"1.2":         "X12",
+	}
+	for route, expect := range cases {
+		if id := identifier(route); id != expect {
+			t.Fatalf("identifier(%q) = %q, expect %q", route, id, expect)
+		}
+	}
+	if id := pushIdentifier("onJoin"); id != "OnJoin" {
+		t.Fatalf("unexpected push identifier: %s", id)
+	}
+	if id := pushIdentifier("chat"); id != "OnChat" {
+		t.Fatalf("unexpected push identifier: %s", id)
+	}
+}
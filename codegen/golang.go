@@ -0,0 +1,314 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aura-studio/nano/schema"
+)
+
+// serializer packages of built-in serializers
+var goSerializers = map[string]string{
+	"json":      "github.com/aura-studio/nano/serialize/json",
+	"protobuf":  "github.com/aura-studio/nano/serialize/protobuf",
+	"rawstring": "github.com/aura-studio/nano/serialize/rawstring",
+	"msgpack":   "github.com/aura-studio/nano/serialize/msgpack",
+	"cbor":      "github.com/aura-studio/nano/serialize/cbor",
+}
+
+type goGenerator struct {
+	opts    Options
+	imports map[string]string // import path to alias
+	aliases map[string]bool
+	buf     bytes.Buffer
+}
+
+// GenerateGo generates typed client stubs for connector.Connector in Go, the
+// payload types are referred from the packages declaring them
+func GenerateGo(s *schema.Schema, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "client"
+	}
+	g := &goGenerator{
+		opts:    opts,
+		imports: make(map[string]string),
+		aliases: make(map[string]bool),
+	}
+	g.importAlias("fmt")
+	g.importAlias("github.com/aura-studio/nano/connector")
+	g.importAlias("github.com/aura-studio/nano/message")
+	g.importAlias("github.com/aura-studio/nano/serialize")
+
+	if err := g.generate(s); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// %s\n\npackage %s\n\n", header, opts.Package)
+	var paths []string
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	out.WriteString("import (\n")
+	for i, p := range paths {
+		// standard packages are grouped before others
+		if i > 0 && !strings.Contains(paths[i-1], ".") && strings.Contains(p, ".") {
+			out.WriteString("\n")
+		}
+		if alias := g.imports[p]; alias != path.Base(p) {
+			fmt.Fprintf(&out, "\t%s %q\n", alias, p)
+		} else {
+			fmt.Fprintf(&out, "\t%q\n", p)
+		}
+	}
+	out.WriteString(")\n\n")
+	out.Write(g.buf.Bytes())
+
+	return format.Source(out.Bytes())
+}
+
+func (g *goGenerator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// importAlias imports the package and returns it's alias
+func (g *goGenerator) importAlias(pkg string) string {
+	if alias, ok := g.imports[pkg]; ok {
+		return alias
+	}
+	base := identifier(path.Base(pkg))
+	base = strings.ToLower(base[:1]) + base[1:]
+	alias := base
+	for i := 1; g.aliases[alias]; i++ {
+		alias = fmt.Sprintf("%s%d", base, i)
+	}
+	g.imports[pkg] = alias
+	g.aliases[alias] = true
+	return alias
+}
+
+// typeExpr returns the Go expression of type, false if the type can not be
+// referred in generated code
+func (g *goGenerator) typeExpr(t *schema.Type) (string, bool) {
+	if t == nil || t.Kind == schema.Unknown {
+		return "", false
+	}
+	if t.Kind == schema.Raw {
+		return "[]byte", true
+	}
+	if t.GoPackage == "" {
+		// builtin types, such as: string, map[string]int
+		return t.GoType, !strings.Contains(t.GoType, ".")
+	}
+	if t.GoPackage == "main" {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(t.GoType, "*")
+	idx := strings.LastIndex(name, ".")
+	if idx < 0 || strings.ContainsAny(name, "[]*() ") {
+		return "", false
+	}
+	expr := g.importAlias(t.GoPackage) + "." + name[idx+1:]
+	if strings.HasPrefix(t.GoType, "*") {
+		expr = "*" + expr
+	}
+	return expr, true
+}
+
+// serializerExpr returns the serializer variable of route, or nil if the
+// connector serializer should be used
+func (g *goGenerator) serializerExpr(s schema.Serializer) string {
+	pkg, ok := goSerializers[s.Name]
+	if !ok {
+		return "nil"
+	}
+	g.importAlias(pkg)
+	return s.Name + "Serializer"
+}
+
+func (g *goGenerator) generate(s *schema.Schema) error {
+	client := g.opts.client()
+	hs, ps := handlers(s), pushes(s)
+
+	// route constants
+	g.printf("// Routes of handlers and pushes\nconst (\n")
+	for _, h := range hs {
+		g.printf("\tRoute%s = %q\n", identifier(h.Route), h.Route)
+	}
+	for _, p := range ps {
+		g.printf("\tPush%s = %q\n", identifier(p.Route), p.Route)
+	}
+	g.printf(")\n\n")
+
+	// serializers
+	var names []string
+	for name := range goSerializers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var used []string
+	for _, h := range hs {
+		used = append(used, h.Serializer.Name)
+	}
+	for _, p := range ps {
+		used = append(used, p.Serializer.Name)
+	}
+	g.printf("var (\n")
+	for _, name := range names {
+		for _, u := range used {
+			if u == name {
+				g.printf("\t%sSerializer = %s.NewSerializer()\n", name, g.importAlias(goSerializers[name]))
+				break
+			}
+		}
+	}
+	g.printf(")\n\n")
+
+	g.printf(`// %[1]s is a typed client based on connector.Connector
+type %[1]s struct {
+	*connector.Connector
+}
+
+// New%[1]s returns a typed client of the connector
+func New%[1]s(c *connector.Connector) *%[1]s {
+	return &%[1]s{Connector: c}
+}
+
+func (c *%[1]s) marshal(s serialize.Serializer, v interface{}) ([]byte, error) {
+	if s == nil {
+		return c.Serialize(v)
+	}
+	return s.Marshal(v)
+}
+
+// data returns the payload of response or push, pending requests receive an
+// error if the response will never arrive
+func (c *%[1]s) data(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case *message.Message:
+		return v.Data, nil
+	case error:
+		return nil, v
+	default:
+		return nil, fmt.Errorf("unexpected data: %%T", v)
+	}
+}
+
+func (c *%[1]s) unmarshal(s serialize.Serializer, data []byte, v interface{}) error {
+	if s == nil {
+		return c.Deserialize(data, v)
+	}
+	return s.Unmarshal(data, v)
+}
+
+`, client)
+
+	for _, h := range hs {
+		if err := g.handler(client, h); err != nil {
+			return err
+		}
+	}
+	for _, p := range ps {
+		g.push(client, p)
+	}
+	return nil
+}
+
+func (g *goGenerator) handler(client string, h *schema.Handler) error {
+	id := identifier(h.Route)
+	req, ok := g.typeExpr(h.Request)
+	if !ok {
+		return fmt.Errorf("codegen: request type %s of %s can not be referred", h.Request.GoType, h.Route)
+	}
+	serializer := g.serializerExpr(h.Serializer)
+
+	marshal := fmt.Sprintf(`data, err := c.marshal(%s, req)
+	if err != nil {
+		return err
+	}`, serializer)
+	if h.Request.Kind == schema.Raw {
+		marshal = "data := req"
+	}
+
+	resp, ok := g.typeExpr(h.Response)
+	switch {
+	case !ok:
+		g.printf(`// %[1]s requests %[2]s, the response type is not declared
+func (c *%[3]s) %[1]s(req %[4]s, cb connector.Callback) error {
+	%[5]s
+	return c.Request(Route%[1]s, data, cb)
+}
+
+`, id, h.Route, client, req, marshal)
+	default:
+		g.printf(`// %[1]s requests %[2]s and receives the response in callback
+func (c *%[3]s) %[1]s(req %[4]s, cb func(%[5]s, error)) error {
+	%[6]s
+	return c.Request(Route%[1]s, data, func(v interface{}) {
+		%[7]s
+	})
+}
+
+`, id, h.Route, client, req, resp, marshal, g.decode(h.Response, resp, serializer))
+	}
+
+	g.printf(`// Notify%[1]s notifies %[2]s
+func (c *%[3]s) Notify%[1]s(req %[4]s) error {
+	%[5]s
+	return c.Notify(Route%[1]s, data)
+}
+
+`, id, h.Route, client, req, marshal)
+	return nil
+}
+
+func (g *goGenerator) push(client string, p *schema.Push) {
+	id := pushIdentifier(p.Route)
+	typ, ok := g.typeExpr(p.Payload)
+	if !ok {
+		g.printf(`// %[1]s subscribes %[2]s, the payload type is not declared
+func (c *%[3]s) %[1]s(cb connector.Callback) {
+	c.On(Push%[4]s, cb)
+}
+
+`, id, p.Route, client, identifier(p.Route))
+		return
+	}
+	g.printf(`// %[1]s subscribes %[2]s
+func (c *%[3]s) %[1]s(cb func(%[4]s, error)) {
+	c.On(Push%[5]s, func(v interface{}) {
+		%[6]s
+	})
+}
+
+`, id, p.Route, client, typ, identifier(p.Route), g.decode(p.Payload, typ, g.serializerExpr(p.Serializer)))
+}
+
+// decode returns the statements which decode message in `v` and call `cb`
+func (g *goGenerator) decode(t *schema.Type, expr, serializer string) string {
+	if t.Kind == schema.Raw {
+		return "cb(c.data(v))"
+	}
+	if strings.HasPrefix(expr, "*") {
+		return fmt.Sprintf(`data, err := c.data(v)
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		resp := new(%s)
+		err = c.unmarshal(%s, data, resp)
+		cb(resp, err)`, expr[1:], serializer)
+	}
+	return fmt.Sprintf(`var resp %s
+		data, err := c.data(v)
+		if err == nil {
+			err = c.unmarshal(%s, data, &resp)
+		}
+		cb(resp, err)`, expr, serializer)
+}
@@ -0,0 +1,26 @@
+// Package testdata declares the component and payload types for tests of code
+// generation, which are referred by the generated clients
+package testdata
+
+import (
+	"github.com/aura-studio/nano/benchmark/testdata"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/session"
+)
+
+type JoinRequest struct {
+	RoomID int64  `json:"roomId"`
+	Name   string `json:"name,omitempty"`
+}
+
+type JoinResponse struct {
+	Members map[string]int `json:"members"`
+}
+
+type RoomComponent struct{ component.Base }
+
+func (c *RoomComponent) Join(s *session.Session, req *JoinRequest) error { return nil }
+
+func (c *RoomComponent) Ping(s *session.Session, ping *testdata.Ping) error { return nil }
+
+func (c *RoomComponent) Raw(s *session.Session, data []byte) error { return nil }
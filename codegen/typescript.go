@@ -0,0 +1,201 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aura-studio/nano/schema"
+)
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+type tsGenerator struct {
+	opts  Options
+	names map[string]string // definition name to TypeScript interface name
+	buf   bytes.Buffer
+}
+
+// GenerateTypeScript generates typed client stubs in TypeScript, payload types
+// are generated as interfaces from the schema definitions, and the transport
+// is abstracted by the `Connector` interface of generated code
+func GenerateTypeScript(s *schema.Schema, opts Options) ([]byte, error) {
+	g := &tsGenerator{opts: opts, names: make(map[string]string)}
+	g.resolveNames(s.Definitions)
+	g.generate(s)
+	return g.buf.Bytes(), nil
+}
+
+func (g *tsGenerator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// resolveNames names interfaces by the type name without package, the full
+// name is used if type names of different packages conflict
+func (g *tsGenerator) resolveNames(definitions map[string]*schema.JSONSchema) {
+	count := make(map[string]int)
+	for name := range definitions {
+		count[shortName(name)]++
+	}
+	for name := range definitions {
+		if short := shortName(name); count[short] == 1 {
+			g.names[name] = identifier(short)
+		} else {
+			g.names[name] = identifier(name)
+		}
+	}
+}
+
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// typeOf returns the TypeScript type of payload
+func (g *tsGenerator) typeOf(t *schema.Type) string {
+	if t == nil {
+		return "unknown"
+	}
+	switch t.Kind {
+	case schema.Raw:
+		return "Uint8Array"
+	case schema.Typed:
+		return g.typeOfSchema(t.Schema)
+	default:
+		return "unknown"
+	}
+}
+
+func (g *tsGenerator) typeOfSchema(s *schema.JSONSchema) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Ref != "" {
+		if name, ok := g.names[schema.DefinitionName(s.Ref)]; ok {
+			return name
+		}
+		return "unknown"
+	}
+	switch s.Type {
+	case "boolean":
+		return "boolean"
+	case "integer", "number":
+		return "number"
+	case "string":
+		return "string"
+	case "array":
+		return fmt.Sprintf("Array<%s>", g.typeOfSchema(s.Items))
+	case "object":
+		if s.Properties != nil {
+			return g.object(s, "")
+		}
+		return fmt.Sprintf("{ [key: string]: %s }", g.typeOfSchema(s.AdditionalProperties))
+	default:
+		return "unknown"
+	}
+}
+
+// object returns the body of an object type, properties are indented by
+// indent, or kept in one line if indent is empty
+func (g *tsGenerator) object(s *schema.JSONSchema, indent string) string {
+	required := make(map[string]bool)
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	var names []string
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("{")
+	for _, name := range names {
+		if indent == "" {
+			sb.WriteString(" ")
+		} else {
+			sb.WriteString("\n" + indent)
+		}
+		key := name
+		if !tsIdentifier.MatchString(key) {
+			key = fmt.Sprintf("%q", key)
+		}
+		if !required[name] {
+			key += "?"
+		}
+		fmt.Fprintf(&sb, "%s: %s;", key, g.typeOfSchema(s.Properties[name]))
+	}
+	if indent == "" {
+		sb.WriteString(" }")
+	} else {
+		sb.WriteString("\n}")
+	}
+	return sb.String()
+}
+
+func (g *tsGenerator) generate(s *schema.Schema) {
+	hs, ps := handlers(s), pushes(s)
+
+	g.printf(`// %s
+
+/** Connector sends messages to nano server and dispatches pushes */
+export interface Connector {
+  request(route: string, data: unknown): Promise<unknown>;
+  notify(route: string, data: unknown): void;
+  on(route: string, callback: (data: unknown) => void): void;
+}
+
+`, header)
+
+	g.printf("/** Routes of handlers */\nexport const Routes = {\n")
+	for _, h := range hs {
+		g.printf("  %s: %q,\n", identifier(h.Route), h.Route)
+	}
+	g.printf("} as const;\n\n")
+
+	g.printf("/** Routes of pushes */\nexport const Pushes = {\n")
+	for _, p := range ps {
+		g.printf("  %s: %q,\n", identifier(p.Route), p.Route)
+	}
+	g.printf("} as const;\n\n")
+
+	var definitions []string
+	for name := range s.Definitions {
+		definitions = append(definitions, name)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return g.names[definitions[i]] < g.names[definitions[j]]
+	})
+	for _, name := range definitions {
+		g.printf("/** %s */\nexport interface %s %s\n\n", name, g.names[name], g.object(s.Definitions[name], "  "))
+	}
+
+	client := g.opts.client()
+	g.printf("/** %[1]s is a typed client of nano server */\nexport class %[1]s {\n", client)
+	g.printf("  constructor(private readonly connector: Connector) {}\n")
+	for _, h := range hs {
+		id := identifier(h.Route)
+		req, resp := g.typeOf(h.Request), g.typeOf(h.Response)
+		g.printf(`
+  /** Requests %[1]s */
+  %[2]s(req: %[3]s): Promise<%[4]s> {
+    return this.connector.request(Routes.%[5]s, req) as Promise<%[4]s>;
+  }
+
+  /** Notifies %[1]s */
+  notify%[5]s(req: %[3]s): void {
+    this.connector.notify(Routes.%[5]s, req);
+  }
+`, h.Route, lowerFirst(id), req, resp, id)
+	}
+	for _, p := range ps {
+		g.printf(`
+  /** Subscribes %[1]s */
+  %[2]s(callback: (payload: %[3]s) => void): void {
+    this.connector.on(Pushes.%[4]s, callback as (data: unknown) => void);
+  }
+`, p.Route, lowerFirst(pushIdentifier(p.Route)), g.typeOf(p.Payload), identifier(p.Route))
+	}
+	g.printf("}\n")
+}
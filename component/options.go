@@ -22,6 +22,7 @@ package component
 
 import (
 	"github.com/aura-studio/nano/scheduler"
+	"github.com/aura-studio/nano/serialize"
 )
 
 type (
@@ -30,6 +31,21 @@ type (
 		renameHandler func(string) string    // rename handler name
 		schedule      scheduler.SchedFunc    // schedule service task
 		dictionary    map[uint16]interface{} // Dictionary info slice
+		serializer    serialize.Serializer   // serializer of all handlers
+		serializers   []handlerSerializer    // serializer overrides of handlers
+		responses     []handlerResponse      // response types of handlers
+		pushes        map[string]interface{} // push routes sent by component
+		anonymous     []interface{}          // handlers callable before authenticated
+	}
+
+	handlerSerializer struct {
+		serializer serialize.Serializer
+		methods    []interface{}
+	}
+
+	handlerResponse struct {
+		response interface{}
+		methods  []interface{}
 	}
 
 	// Option used to customize handler
@@ -64,3 +80,48 @@ func WithDictionary(dict map[uint16]interface{}) Option {
 		opt.dictionary = dict
 	}
 }
+
+// WithSerializer sets the serializer of all handlers in the component, the
+// application serializer is used if not set
+func WithSerializer(serializer serialize.Serializer) Option {
+	return func(opt *options) {
+		opt.serializer = serializer
+	}
+}
+
+// WithHandlerSerializer overrides the serializer of specified handler methods,
+// such as: WithHandlerSerializer(json.NewSerializer(), (*Room).Join)
+func WithHandlerSerializer(serializer serialize.Serializer, methods ...interface{}) Option {
+	return func(opt *options) {
+		opt.serializers = append(opt.serializers, handlerSerializer{serializer, methods})
+	}
+}
+
+// WithHandlerResponse declares the response type of specified handler methods,
+// which is only used to describe the handlers, such as exporting schema
+func WithHandlerResponse(response interface{}, methods ...interface{}) Option {
+	return func(opt *options) {
+		opt.responses = append(opt.responses, handlerResponse{response, methods})
+	}
+}
+
+// WithPush declares a push route and it's payload type sent by the component,
+// which describes the component, such as exporting schema, and the route is
+// serialized by the serializer of the component set by WithSerializer
+func WithPush(route string, payload interface{}) Option {
+	return func(opt *options) {
+		if opt.pushes == nil {
+			opt.pushes = make(map[string]interface{})
+		}
+		opt.pushes[route] = payload
+	}
+}
+
+// WithAnonymousRoutes marks specified handler methods callable before the
+// session is bound to a UID, when authentication is enforced by the gate, such
+// as: WithAnonymousRoutes((*Account).Register, (*Account).Version)
+func WithAnonymousRoutes(methods ...interface{}) Option {
+	return func(opt *options) {
+		opt.anonymous = append(opt.anonymous, methods...)
+	}
+}
@@ -25,27 +25,33 @@ import (
 	"reflect"
 
 	"github.com/aura-studio/nano/scheduler"
+	"github.com/aura-studio/nano/serialize"
 )
 
 type (
 	//Handler represents a message.Message's handler's meta information.
 	Handler struct {
-		Receiver reflect.Value  // receiver of method
-		Method   reflect.Method // method stub
-		Type     reflect.Type   // low-level type of method
-		IsRawArg bool           // whether the data need to serialize
-		Code     uint16         // Route compressed code
+		Receiver   reflect.Value        // receiver of method
+		Method     reflect.Method       // method stub
+		Type       reflect.Type         // low-level type of method
+		IsRawArg   bool                 // whether the data need to serialize
+		Code       uint16               // Route compressed code
+		Serializer serialize.Serializer // serializer of handler, nil if using application serializer
+		Response   reflect.Type         // declared response type, nil if not declared
+		Anonymous  bool                 // whether callable before authenticated
 	}
 
 	// Service implements a specific service, some of it's methods will be
 	// called when the correspond events is occurred.
 	Service struct {
-		Name     string              // name of service
-		Type     reflect.Type        // type of the receiver
-		Receiver reflect.Value       // receiver of methods for the service
-		Handlers map[string]*Handler // registered methods
-		Schedule scheduler.SchedFunc // tasks are pushed in and wait to be handled
-		Options  options             // options
+		Name       string                  // name of service
+		Type       reflect.Type            // type of the receiver
+		Receiver   reflect.Value           // receiver of methods for the service
+		Handlers   map[string]*Handler     // registered methods
+		Schedule   scheduler.SchedFunc     // tasks are pushed in and wait to be handled
+		Pushes     map[string]reflect.Type // declared push routes and payload types
+		Serializer serialize.Serializer    // serializer of component, nil for the application serializer
+		Options    options                 // options
 	}
 )
 
@@ -71,6 +77,11 @@ func NewService(comp Component, opts []Option) *Service {
 	} else {
 		s.Schedule = scheduler.Schedule
 	}
+	s.Serializer = s.Options.serializer
+	s.Pushes = make(map[string]reflect.Type)
+	for route, payload := range s.Options.pushes {
+		s.Pushes[route] = reflect.TypeOf(payload)
+	}
 
 	return s
 }
@@ -100,11 +111,43 @@ func (s *Service) suitableHandlerMethods(typ reflect.Type) map[string]*Handler {
 				}
 			}
 
+			// find handler serializer, the last override wins
+			serializer := s.Options.serializer
+			for _, hs := range s.Options.serializers {
+				for _, fn := range hs.methods {
+					if reflect.ValueOf(fn).Pointer() == method.Func.Pointer() {
+						serializer = hs.serializer
+					}
+				}
+			}
+
+			// find declared response type
+			var response reflect.Type
+			for _, hr := range s.Options.responses {
+				for _, fn := range hr.methods {
+					if reflect.ValueOf(fn).Pointer() == method.Func.Pointer() {
+						response = reflect.TypeOf(hr.response)
+					}
+				}
+			}
+
+			// find anonymous handlers
+			anonymous := false
+			for _, fn := range s.Options.anonymous {
+				if reflect.ValueOf(fn).Pointer() == method.Func.Pointer() {
+					anonymous = true
+					break
+				}
+			}
+
 			methods[mn] = &Handler{
-				Method:   method,
-				Type:     mt.In(2),
-				IsRawArg: raw,
-				Code:     code,
+				Method:     method,
+				Type:       mt.In(2),
+				IsRawArg:   raw,
+				Code:       code,
+				Serializer: serializer,
+				Response:   response,
+				Anonymous:  anonymous,
 			}
 		}
 	}
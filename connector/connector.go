@@ -1,9 +1,14 @@
 package connector
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,10 +16,14 @@ import (
 	"github.com/aura-studio/nano/env"
 	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/serialize/protobuf"
+	"github.com/aura-studio/nano/upgrader"
+	"github.com/gorilla/websocket"
 
 	"github.com/aura-studio/nano/codec"
 	"github.com/aura-studio/nano/message"
 	"github.com/aura-studio/nano/packet"
+	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/replay"
 )
 
 type (
@@ -23,18 +32,31 @@ type (
 	// when the correspond events is occurred.
 	Callback func(data interface{})
 
-	// Connector is a tiny Nano client
+	// Connector is a tiny Nano client, it's safe for concurrent use by multiple
+	// goroutines, the lifecycle callbacks should be set before Start
 	Connector struct {
 		Options
 
-		conn           net.Conn       // low-level connection
-		codec          *codec.Decoder // decoder
-		die            chan struct{}  // connector close channel
-		chSend         chan []byte    // send queue
-		mid            uint64         // message id
-		connected      int32          // connected state 1: disconnected : 0
-		connectedEvent Callback       // connected callback
-		chReady        chan struct{}  // connector ready channel
+		addr    string        // server address
+		timeout time.Duration // dial timeout
+
+		mu        sync.Mutex
+		conn      net.Conn      // low-level connection
+		connDie   chan struct{} // current connection close channel
+		chSend    chan []byte   // send queue of current connection
+		secret    []byte        // secret of signatures, cleared when disconnected
+		die       chan struct{} // connector close channel
+		closeOnce sync.Once
+		mid       uint64        // last allocated message id, accessed atomically
+		muSend    sync.Mutex    // keeps messages queued in the order of ids
+		connected int32         // connected state 1: disconnected : 0
+		chReady   chan struct{} // connector ready channel
+
+		// lifecycle callbacks
+		connectedEvent    Callback // connected callback
+		disconnectedEvent Callback // disconnected callback, data is the error
+		reconnectingEvent Callback // reconnecting callback, data is the attempt
+		reconnectedEvent  Callback // reconnected callback
 
 		// events handler
 		muEvents        sync.RWMutex
@@ -43,11 +65,18 @@ type (
 
 		// response handler
 		muResponses sync.RWMutex
-		responses   map[uint64]Callback
+		responses   map[uint64]*pendingRequest
 
 		routes map[string]uint16 // copy system routes for agent
 		codes  map[uint16]string // copy system codes for agent
 	}
+
+	// pendingRequest is a request waiting for response
+	pendingRequest struct {
+		msg      *message.Message
+		callback Callback
+		timer    *time.Timer // timeout timer, nil if no timeout
+	}
 )
 
 // NewConnector create a new Connector
@@ -57,18 +86,19 @@ func NewConnector(opts ...Option) *Connector {
 			dictionary: make(map[string]uint16),
 			serializer: protobuf.NewSerializer(),
 		},
-		die:             make(chan struct{}),
-		codec:           codec.NewDecoder(),
-		chSend:          make(chan []byte, 256),
-		mid:             1,
-		connected:       0,
-		connectedEvent:  func(data interface{}) {},
-		chReady:         make(chan struct{}, 1),
-		events:          map[string]Callback{},
-		unexpectedEvent: func(data interface{}) {},
-		responses:       map[uint64]Callback{},
-		routes:          make(map[string]uint16),
-		codes:           make(map[uint16]string),
+		die:               make(chan struct{}),
+		mid:               0,
+		connected:         0,
+		connectedEvent:    func(data interface{}) {},
+		disconnectedEvent: func(data interface{}) {},
+		reconnectingEvent: func(data interface{}) {},
+		reconnectedEvent:  func(data interface{}) {},
+		chReady:           make(chan struct{}, 1),
+		events:            map[string]Callback{},
+		unexpectedEvent:   func(data interface{}) {},
+		responses:         map[uint64]*pendingRequest{},
+		routes:            make(map[string]uint16),
+		codes:             make(map[uint16]string),
 	}
 
 	for i := range opts {
@@ -84,44 +114,135 @@ func NewConnector(opts ...Option) *Connector {
 	return c
 }
 
-// StartWithTimeout connects to server with custom timeout
+// StartWithTimeout connects to server with custom timeout, the address can be
+// a WebSocket URL, such as: ws://127.0.0.1:3250/nano, wss://example.com/nano
 func (c *Connector) StartWithTimeout(addr string, timeout time.Duration) error {
-	conn, err := net.DialTimeout("tcp", addr, timeout)
+	c.addr, c.timeout = addr, timeout
+	conn, err := c.dial(addr, timeout)
 	if err != nil {
 		return err
 	}
 
-	c.conn = conn
-
-	go c.write()
-
-	go c.read()
+	if !c.serve(conn) {
+		conn.Close()
+		return ErrClosed
+	}
 
-	atomic.StoreInt32(&c.connected, 1)
 	go c.connectedEvent(nil)
 	c.chReady <- struct{}{}
 
 	return nil
 }
 
-// Start connects to the server and send/recv between the c/s
-func (c *Connector) Start(addr string) error {
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		return err
+// serve starts reading and writing the connection, returns false if the
+// connector is closed
+func (c *Connector) serve(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.die:
+		return false
+	default:
 	}
 
 	c.conn = conn
+	c.connDie = make(chan struct{})
+	c.chSend = make(chan []byte, 256)
+	atomic.StoreInt32(&c.connected, 1)
 
-	go c.write()
+	go c.write(conn, c.chSend, c.connDie)
 
-	go c.read()
+	go c.read(conn)
 
-	atomic.StoreInt32(&c.connected, 1)
-	go c.connectedEvent(nil)
-	c.chReady <- struct{}{}
+	return true
+}
 
-	return nil
+// disconnect cleans up the lost connection, pending requests are failed, or
+// kept to be replayed if reconnection is enabled
+func (c *Connector) disconnect(conn net.Conn, err error) {
+	c.mu.Lock()
+	if c.conn != conn {
+		// handled by another goroutine
+		c.mu.Unlock()
+		return
+	}
+	c.conn = nil
+	c.chSend = nil
+	c.secret = nil
+	close(c.connDie)
+	atomic.StoreInt32(&c.connected, 0)
+	c.mu.Unlock()
+
+	conn.Close()
+
+	closed := c.closed()
+	if closed {
+		err = ErrClosed
+	}
+	c.disconnectedEvent(err)
+
+	if closed {
+		c.failPending(ErrClosed, false)
+		return
+	}
+
+	if c.reconnectPolicy == nil {
+		c.failPending(ErrDisconnected, false)
+		c.Close()
+		return
+	}
+
+	c.failPending(ErrDisconnected, true)
+	go c.reconnect()
+}
+
+func (c *Connector) closed() bool {
+	select {
+	case <-c.die:
+		return true
+	default:
+		return false
+	}
+}
+
+// Start connects to the server and send/recv between the c/s
+func (c *Connector) Start(addr string) error {
+	return c.StartWithTimeout(addr, 0)
+}
+
+// dial connects to server by the custom dialer, or by WebSocket if the address
+// is a WebSocket URL or the WebSocket path is set, otherwise by TCP
+func (c *Connector) dial(addr string, timeout time.Duration) (net.Conn, error) {
+	if c.dialer != nil {
+		return c.dialer(addr, timeout)
+	}
+
+	if !strings.HasPrefix(addr, "ws://") && !strings.HasPrefix(addr, "wss://") {
+		if c.wsPath == "" {
+			return net.DialTimeout("tcp", addr, timeout)
+		}
+		addr = "ws://" + addr
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Path == "" || u.Path == "/") && c.wsPath != "" {
+		u.Path = "/" + strings.TrimPrefix(c.wsPath, "/")
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: timeout,
+		TLSClientConfig:  c.tlsConfig,
+	}
+	conn, _, err := dialer.Dial(u.String(), c.wsHeader)
+	if err != nil {
+		return nil, err
+	}
+	return upgrader.NewWebSocketConn(conn), nil
 }
 
 func (c *Connector) Ready() <-chan struct{} {
@@ -138,13 +259,89 @@ func (c *Connector) OnConnected(callback Callback) {
 	c.connectedEvent = callback
 }
 
-// GetMid returns current message id
+// OnDisconnected set the callback which will be called when the connection is
+// lost or closed, the data of callback is the error
+func (c *Connector) OnDisconnected(callback Callback) {
+	c.disconnectedEvent = callback
+}
+
+// OnReconnecting set the callback which will be called before each reconnect
+// attempt, the data of callback is the attempt starts from 1
+func (c *Connector) OnReconnecting(callback Callback) {
+	c.reconnectingEvent = callback
+}
+
+// OnReconnected set the callback which will be called when the client reconnected
+// to the server
+func (c *Connector) OnReconnected(callback Callback) {
+	c.reconnectedEvent = callback
+}
+
+// GetMid returns the id of next request
 func (c *Connector) GetMid() uint64 {
-	return c.mid
+	return atomic.LoadUint64(&c.mid) + 1
 }
 
-// Request send a request to server and register a callbck for the response
+// nextMid allocates a message id for request
+func (c *Connector) nextMid() uint64 {
+	return atomic.AddUint64(&c.mid, 1)
+}
+
+// Request send a request to server and register a callbck for the response,
+// the callback receives the *message.Message of response, a *pipeline.Error if
+// the server responds an error, or an error if the response will never arrive,
+// such as: ErrDisconnected, ErrRequestTimeout. The request timeout set by
+// WithRequestTimeout is applied.
 func (c *Connector) Request(route string, v interface{}, callback Callback) error {
+	_, err := c.request(route, v, c.requestTimeout, callback)
+	return err
+}
+
+// RequestTimeout send a request to server with a timeout, the callback receives
+// ErrRequestTimeout if the response does not arrive in time, no timeout if zero
+func (c *Connector) RequestTimeout(route string, v interface{}, timeout time.Duration, callback Callback) error {
+	_, err := c.request(route, v, timeout, callback)
+	return err
+}
+
+// RequestSync send a request to server and blocks until the response arrives,
+// the response is deserialized into resp unless resp is nil, a *[]byte receives
+// the raw data. Errors responded by the server are returned as *pipeline.Error.
+// The request is abandoned when ctx is done, and the request timeout set by
+// WithRequestTimeout is applied.
+func (c *Connector) RequestSync(ctx context.Context, route string, v interface{}, resp interface{}) error {
+	ch := make(chan interface{}, 1)
+	mid, err := c.request(route, v, c.requestTimeout, func(data interface{}) {
+		ch <- data
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case data := <-ch:
+		switch data := data.(type) {
+		case *message.Message:
+			if resp == nil {
+				return nil
+			}
+			if raw, ok := resp.(*[]byte); ok {
+				*raw = data.Data
+				return nil
+			}
+			return c.Deserialize(data.Data, resp)
+		case error:
+			return data
+		default:
+			return fmt.Errorf("unexpected response: %T", data)
+		}
+	case <-ctx.Done():
+		c.takeResponseHandler(mid)
+		return ctx.Err()
+	}
+}
+
+func (c *Connector) request(route string, v interface{}, timeout time.Duration, callback Callback) (uint64, error) {
 	var data []byte
 	switch v := v.(type) {
 	case []byte:
@@ -153,7 +350,7 @@ func (c *Connector) Request(route string, v interface{}, callback Callback) erro
 		var err error
 		data, err = c.Serialize(v)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 
@@ -161,17 +358,22 @@ func (c *Connector) Request(route string, v interface{}, callback Callback) erro
 		Type:     message.Request,
 		ShortVer: env.ShortVersion,
 		Route:    route,
-		ID:       c.mid,
 		Data:     data,
 	}
 
-	c.setResponseHandler(c.mid, callback)
+	// ids are allocated and queued in order, gates with replay protection
+	// reject requests queued before a request with a greater id
+	c.muSend.Lock()
+	defer c.muSend.Unlock()
+
+	msg.ID = c.nextMid()
+	c.setResponseHandler(&pendingRequest{msg: msg, callback: callback}, timeout)
 	if err := c.sendMessage(msg); err != nil {
-		c.setResponseHandler(c.mid, nil)
-		return err
+		c.takeResponseHandler(msg.ID)
+		return 0, err
 	}
 
-	return nil
+	return msg.ID, nil
 }
 
 // Notify send a notification to server
@@ -194,6 +396,9 @@ func (c *Connector) Notify(route string, v interface{}) error {
 		Route:    route,
 		Data:     data,
 	}
+
+	c.muSend.Lock()
+	defer c.muSend.Unlock()
 	return c.sendMessage(msg)
 }
 
@@ -207,16 +412,26 @@ func (c *Connector) On(event string, callback Callback) {
 
 // OnUnexpectedEvent sets callback for events that are not "On"
 func (c *Connector) OnUnexpectedEvent(callback Callback) {
+	c.muEvents.Lock()
+	defer c.muEvents.Unlock()
+
 	c.unexpectedEvent = callback
 }
 
 // Close closes the connection, and shutdown the benchmark
 func (c *Connector) Close() {
-	defer func() {
-		_ = recover()
-	}()
-	c.conn.Close()
-	close(c.die)
+	c.closeOnce.Do(func() {
+		close(c.die)
+
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+
+		// pending requests are failed after the read goroutine exits
+		if conn != nil {
+			conn.Close()
+		}
+	})
 }
 
 // Connected returns the status whether connector is conncected
@@ -260,89 +475,202 @@ func (c *Connector) Deserialize(data []byte, v interface{}) error {
 	return nil
 }
 
+// eventHandler returns the callback of event, or the unexpected event callback
+// if the event is not registered
 func (c *Connector) eventHandler(event string) (Callback, bool) {
 	c.muEvents.RLock()
 	defer c.muEvents.RUnlock()
 
 	cb, ok := c.events[event]
-	return cb, ok
+	if !ok {
+		return c.unexpectedEvent, false
+	}
+	return cb, true
 }
 
-func (c *Connector) responseHandler(mid uint64) (Callback, bool) {
-	c.muResponses.RLock()
-	defer c.muResponses.RUnlock()
+// takeResponseHandler returns and removes the callback of request, and stops
+// the timeout timer
+func (c *Connector) takeResponseHandler(mid uint64) (Callback, bool) {
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
 
-	cb, ok := c.responses[mid]
-	return cb, ok
+	req, ok := c.responses[mid]
+	if !ok {
+		return nil, false
+	}
+	delete(c.responses, mid)
+	if req.timer != nil {
+		req.timer.Stop()
+	}
+	return req.callback, true
 }
 
-func (c *Connector) setResponseHandler(mid uint64, cb Callback) {
+// setResponseHandler registers the pending request, the timeout timer is armed
+// after registered, so that it always finds the request to remove
+func (c *Connector) setResponseHandler(req *pendingRequest, timeout time.Duration) {
 	c.muResponses.Lock()
 	defer c.muResponses.Unlock()
 
-	if cb == nil {
+	mid := req.msg.ID
+	c.responses[mid] = req
+	if timeout > 0 {
+		req.timer = time.AfterFunc(timeout, func() {
+			if cb, ok := c.takeResponseHandler(mid); ok {
+				cb(ErrRequestTimeout)
+			}
+		})
+	}
+}
+
+// failPending calls the callbacks of pending requests with err, requests of
+// idempotent routes are kept if keepIdempotent
+func (c *Connector) failPending(err error, keepIdempotent bool) {
+	c.muResponses.Lock()
+	var failed []*pendingRequest
+	for mid, req := range c.responses {
+		if keepIdempotent && c.idempotent[req.msg.Route] {
+			continue
+		}
+		failed = append(failed, req)
 		delete(c.responses, mid)
-	} else {
-		c.responses[mid] = cb
+		if req.timer != nil {
+			req.timer.Stop()
+		}
+	}
+	c.muResponses.Unlock()
+
+	for _, req := range failed {
+		req.callback(err)
 	}
 }
 
-func (c *Connector) sendMessage(msg *message.Message) error {
+// replayPending resends the pending requests after reconnected
+func (c *Connector) replayPending() {
+	// new requests are queued after the pending requests
+	c.muSend.Lock()
+	c.muResponses.RLock()
+	var pending []*message.Message
+	for _, req := range c.responses {
+		pending = append(pending, req.msg)
+	}
+	c.muResponses.RUnlock()
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	var failed []uint64
+	var errs []error
+	for _, msg := range pending {
+		payload, err := c.encode(msg)
+		if err == nil {
+			err = c.send(payload)
+		}
+		if err != nil {
+			failed, errs = append(failed, msg.ID), append(errs, err)
+		}
+	}
+	c.muSend.Unlock()
+
+	// callbacks may send requests
+	for i, mid := range failed {
+		if cb, ok := c.takeResponseHandler(mid); ok {
+			cb(errs[i])
+		}
+	}
+}
+
+// SetSecret signs messages sent after that with the secret, which should be the
+// same as the secret set by replay.SetSecret on the gate. The secret is cleared
+// when disconnected, because the session on the gate is closed.
+func (c *Connector) SetSecret(secret []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.secret = secret
+}
+
+func (c *Connector) encode(msg *message.Message) ([]byte, error) {
+	c.mu.Lock()
+	secret := c.secret
+	c.mu.Unlock()
+
+	if len(secret) > 0 {
+		// sign a copy, pending requests may be encoded again after reconnected
+		signed := *msg
+		if signed.Type == message.Notify {
+			// signed notifies are numbered by message ids as requests, which
+			// is called with muSend held by Notify
+			signed.ID = c.nextMid()
+		}
+		signed.Data = replay.Sign(secret, signed.Type, signed.ID, signed.Route, signed.Data)
+		msg = &signed
+	}
+
 	data, err := message.Encode(msg, c.routes)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return codec.Encode(data)
+}
 
-	payload, err := codec.Encode(data)
+func (c *Connector) sendMessage(msg *message.Message) error {
+	payload, err := c.encode(msg)
 	if err != nil {
 		return err
 	}
 
-	c.mid++
-	c.send(payload)
-
-	return nil
+	return c.send(payload)
 }
 
-func (c *Connector) write() {
-	defer close(c.chSend)
-
+func (c *Connector) write(conn net.Conn, chSend chan []byte, done chan struct{}) {
 	for {
 		select {
-		case data := <-c.chSend:
-			if _, err := c.conn.Write(data); err != nil {
+		case data := <-chSend:
+			if _, err := conn.Write(data); err != nil {
 				log.Errorln(err)
-				c.Close()
+				c.disconnect(conn, err)
+				return
 			}
 
-		case <-c.die:
-			atomic.StoreInt32(&c.connected, 0)
+		case <-done:
 			return
 		}
 	}
 }
 
-func (c *Connector) send(data []byte) {
-	c.chSend <- data
+func (c *Connector) send(data []byte) error {
+	c.mu.Lock()
+	chSend, done := c.chSend, c.connDie
+	c.mu.Unlock()
+
+	if chSend == nil {
+		return ErrNotConnected
+	}
+
+	select {
+	case chSend <- data:
+		return nil
+	case <-done:
+		return ErrNotConnected
+	}
 }
 
-func (c *Connector) read() {
+func (c *Connector) read(conn net.Conn) {
+	decoder := codec.NewDecoder()
 	buf := make([]byte, 2048)
 
 	for {
-		n, err := c.conn.Read(buf)
+		n, err := conn.Read(buf)
 		if err != nil {
-			if err != io.EOF {
-				log.Infof("Read [%s], connector will be closed immediately", err.Error())
+			if err != io.EOF && !c.closed() {
+				log.Infof("Read [%s], connection will be closed immediately", err.Error())
 			}
-			c.Close()
+			c.disconnect(conn, err)
 			return
 		}
 
-		packets, err := c.codec.Decode(buf[:n])
+		packets, err := decoder.Decode(buf[:n])
 		if err != nil {
 			log.Errorln(err)
-			c.Close()
+			c.disconnect(conn, err)
 			return
 		}
 
@@ -354,7 +682,11 @@ func (c *Connector) read() {
 }
 
 func (c *Connector) processPacket(p *packet.Packet) {
-	msg, _, err := message.Decode(p.Data, c.codes)
+	// packet data refers to the decoder buffer, which is reused by next read,
+	// copy it since messages may be retained by callbacks
+	data := make([]byte, len(p.Data))
+	copy(data, p.Data)
+	msg, _, err := message.Decode(data, c.codes)
 	if err != nil {
 		log.Errorln(err)
 		return
@@ -365,21 +697,25 @@ func (c *Connector) processPacket(p *packet.Packet) {
 func (c *Connector) processMessage(msg *message.Message) {
 	switch msg.Type {
 	case message.Push:
-		cb, ok := c.eventHandler(msg.Route)
-		if ok {
-			cb(msg)
-		} else {
-			c.unexpectedEvent(msg)
-		}
+		cb, _ := c.eventHandler(msg.Route)
+		cb(msg)
 
 	case message.Response:
-		cb, ok := c.responseHandler(msg.ID)
+		cb, ok := c.takeResponseHandler(msg.ID)
 		if !ok {
 			log.Errorln("response handler not found", msg.ID)
 			return
 		}
 
+		if msg.Error {
+			e, err := pipeline.DecodeError(msg.Data)
+			if err != nil {
+				cb(err)
+				return
+			}
+			cb(e)
+			return
+		}
 		cb(msg)
-		c.setResponseHandler(msg.ID, nil)
 	}
 }
@@ -0,0 +1,289 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano/codec"
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/upgrader"
+)
+
+// serveConn reads requests from conn and responds "re:" + data if the handle
+// func returns respond, the connection is closed if it returns !keep
+func serveConn(t *testing.T, conn net.Conn, handle func(req *message.Message) (respond, keep bool)) {
+	defer conn.Close()
+
+	decoder := codec.NewDecoder()
+	buf := make([]byte, 2048)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		packets, err := decoder.Decode(buf[:n])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, p := range packets {
+			req, _, err := message.Decode(append([]byte(nil), p.Data...), nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if handle != nil {
+				respond, keep := handle(req)
+				if !keep {
+					return
+				}
+				if !respond {
+					continue
+				}
+			}
+			data, err := message.Encode(&message.Message{
+				Type: message.Response,
+				ID:   req.ID,
+				Data: append([]byte("re:"), req.Data...),
+			}, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			payload, _ := codec.Encode(data)
+			if _, err := conn.Write(payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func waitResponse(t *testing.T, ch chan interface{}) interface{} {
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(3 * time.Second):
+		t.Fatal("response timeout")
+		return nil
+	}
+}
+
+func TestConnectorWebSocket(t *testing.T) {
+	u := upgrader.NewWebSocket()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nano" || r.Header.Get("X-Token") != "token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		serveConn(t, conn, nil)
+	}))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("X-Token", "token")
+	c := NewConnector(WithWSPath("/nano"), WithWSHeader(header))
+	if err := c.StartWithTimeout(strings.Replace(server.URL, "http://", "ws://", 1), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	chResp := make(chan interface{}, 1)
+	if err := c.Request("Room.Join", []byte("hello"), func(data interface{}) {
+		chResp <- data
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := waitResponse(t, chResp)
+	if string(resp.(*message.Message).Data) != "re:hello" {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+}
+
+func TestConnectorReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		// the first connection is lost after both requests received
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		received := 0
+		serveConn(t, conn, func(req *message.Message) (bool, bool) {
+			received++
+			return true, received < 2
+		})
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(t, conn, nil)
+		}
+	}()
+
+	policy := ReconnectPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}
+	c := NewConnector(WithReconnect(policy), WithIdempotentRoutes("Room.Get"))
+	chEvents := make(chan interface{}, 8)
+	c.OnDisconnected(func(data interface{}) { chEvents <- "disconnected" })
+	c.OnReconnecting(func(data interface{}) { chEvents <- "reconnecting" })
+	c.OnReconnected(func(data interface{}) { chEvents <- "reconnected" })
+	if err := c.Start(listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	chGet, chJoin := make(chan interface{}, 1), make(chan interface{}, 1)
+	if err := c.Request("Room.Get", []byte("get"), func(data interface{}) { chGet <- data }); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Request("Room.Join", []byte("join"), func(data interface{}) { chJoin <- data }); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := waitResponse(t, chJoin); resp != ErrDisconnected {
+		t.Fatalf("unexpected response of non-idempotent request: %v", resp)
+	}
+	resp, ok := waitResponse(t, chGet).(*message.Message)
+	if !ok || string(resp.Data) != "re:get" {
+		t.Fatalf("unexpected response of replayed request: %v", resp)
+	}
+
+	for _, expect := range []string{"disconnected", "reconnecting", "reconnected"} {
+		if event := waitResponse(t, chEvents); event != expect {
+			t.Fatalf("unexpected event %v, expect %s", event, expect)
+		}
+	}
+	if !c.Connected() {
+		t.Fatal("connector should be connected")
+	}
+}
+
+func TestConnectorRequestTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// "Room.Slow" is never responded
+			go serveConn(t, conn, func(req *message.Message) (bool, bool) {
+				return req.Route != "Room.Slow", true
+			})
+		}
+	}()
+
+	c := NewConnector(WithRequestTimeout(50 * time.Millisecond))
+	if err := c.Start(listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	chResp := make(chan interface{}, 1)
+	if err := c.Request("Room.Slow", []byte("slow"), func(data interface{}) { chResp <- data }); err != nil {
+		t.Fatal(err)
+	}
+	if resp := waitResponse(t, chResp); resp != ErrRequestTimeout {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+
+	var raw []byte
+	if err := c.RequestSync(context.Background(), "Room.Fast", []byte("fast"), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "re:fast" {
+		t.Fatalf("unexpected response: %s", raw)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.RequestSync(ctx, "Room.Slow", []byte("slow"), nil); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the timer may fire before the request is sent
+	if err := c.RequestTimeout("Room.Slow", []byte("slow"), time.Nanosecond, func(data interface{}) { chResp <- data }); err != nil {
+		t.Fatal(err)
+	}
+	if resp := waitResponse(t, chResp); resp != ErrRequestTimeout {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+
+	c.muResponses.RLock()
+	pending := len(c.responses)
+	c.muResponses.RUnlock()
+	if pending != 0 {
+		t.Fatalf("%d pending requests are not cleaned", pending)
+	}
+}
+
+func TestConnectorConcurrentRequests(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		serveConn(t, conn, nil)
+	}()
+
+	c := NewConnector()
+	if err := c.Start(listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	const goroutines, requests = 16, 32
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				data := fmt.Sprintf("%d-%d", i, j)
+				var resp []byte
+				if err := c.RequestSync(context.Background(), "Room.Echo", []byte(data), &resp); err != nil {
+					t.Error(err)
+					return
+				}
+				if string(resp) != "re:"+data {
+					t.Errorf("response mismatch: %s, expect re:%s", resp, data)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if mid := c.GetMid(); mid != goroutines*requests+1 {
+		t.Fatalf("unexpected next message id: %d", mid)
+	}
+}
@@ -0,0 +1,13 @@
+package connector
+
+import "errors"
+
+// Errors that could be occurred in connector, pending request callbacks receive
+// them as data when the response will never arrive.
+var (
+	ErrNotConnected    = errors.New("connector is not connected")
+	ErrClosed          = errors.New("connector closed")
+	ErrDisconnected    = errors.New("connection lost before response")
+	ErrReconnectFailed = errors.New("reconnect attempts exhausted")
+	ErrRequestTimeout  = errors.New("request timeout")
+)
@@ -1,6 +1,11 @@
 package connector
 
 import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
 	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/serialize"
 )
@@ -12,11 +17,21 @@ type (
 		dictionary map[string]uint16    // Dictionary info
 		serializer serialize.Serializer // serializer for connector
 		wsPath     string               //websocket path
+		wsHeader   http.Header          // websocket handshake header
+		tlsConfig  *tls.Config          // tls config of wss
 		logger     log.Logger           // logger
+
+		reconnectPolicy *ReconnectPolicy // reconnect policy, no reconnection if nil
+		idempotent      map[string]bool  // routes replayed after reconnected
+		requestTimeout  time.Duration    // default request timeout, no timeout if zero
+		dialer          Dialer           // custom dialer, such as in-memory pipes
 	}
 
 	// Option used to customize handler
 	Option func(options *Options)
+
+	// Dialer connects to the server address in timeout
+	Dialer func(addr string, timeout time.Duration) (net.Conn, error)
 )
 
 // WithName is used to name connector
@@ -48,6 +63,58 @@ func WithWSPath(path string) Option {
 	}
 }
 
+// WithWSHeader sets the header sent in the websocket handshake, such as:
+// Authorization, Cookie
+func WithWSHeader(header http.Header) Option {
+	return func(opt *Options) {
+		opt.wsHeader = header
+	}
+}
+
+// WithTLSConfig sets the tls config used to dial wss:// address
+func WithTLSConfig(config *tls.Config) Option {
+	return func(opt *Options) {
+		opt.tlsConfig = config
+	}
+}
+
+// WithReconnect enables reconnection after the connection is lost
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(opt *Options) {
+		opt.reconnectPolicy = &policy
+	}
+}
+
+// WithIdempotentRoutes marks the routes safe to retry, pending requests of these
+// routes are replayed after reconnected, other pending requests are failed with
+// ErrDisconnected once the connection is lost
+func WithIdempotentRoutes(routes ...string) Option {
+	return func(opt *Options) {
+		if opt.idempotent == nil {
+			opt.idempotent = make(map[string]bool)
+		}
+		for _, route := range routes {
+			opt.idempotent[route] = true
+		}
+	}
+}
+
+// WithRequestTimeout sets the default timeout of requests, callbacks of requests
+// receive ErrRequestTimeout if the response does not arrive in time
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(opt *Options) {
+		opt.requestTimeout = timeout
+	}
+}
+
+// WithDialer overrides how the connector connects to server, the address is
+// passed to dialer as is, such as in-memory pipes in tests
+func WithDialer(dialer Dialer) Option {
+	return func(opt *Options) {
+		opt.dialer = dialer
+	}
+}
+
 // WithLogger overrides the default logger
 func WithLogger(l log.Logger) Option {
 	return func(opt *Options) {
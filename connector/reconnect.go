@@ -0,0 +1,81 @@
+package connector
+
+import (
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy controls how connector reconnects after the connection is lost
+type ReconnectPolicy struct {
+	MaxAttempts    int           // max attempts of a reconnection, unlimited if zero
+	InitialBackoff time.Duration // delay before the first attempt
+	MaxBackoff     time.Duration // max delay between attempts
+	Multiplier     float64       // factor applied to the delay after each attempt
+	Jitter         float64       // randomization factor in [0, 1] of each delay
+}
+
+// DefaultReconnectPolicy returns a policy which retries 10 times with exponential
+// backoff from 500ms to 30s
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Backoff returns the delay before the attempt, attempt starts from 1
+func (p ReconnectPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(delay)
+}
+
+// reconnect dials the server until connected or attempts exhausted, pending
+// idempotent requests are replayed after reconnected
+func (c *Connector) reconnect() {
+	policy := *c.reconnectPolicy
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		c.reconnectingEvent(attempt)
+
+		select {
+		case <-time.After(policy.Backoff(attempt)):
+		case <-c.die:
+			c.failPending(ErrClosed, false)
+			return
+		}
+
+		conn, err := c.dial(c.addr, c.timeout)
+		if err != nil {
+			continue
+		}
+		if !c.serve(conn) {
+			conn.Close()
+			c.failPending(ErrClosed, false)
+			return
+		}
+		c.replayPending()
+		c.reconnectedEvent(nil)
+		return
+	}
+
+	c.failPending(ErrReconnectFailed, false)
+	c.Close()
+}
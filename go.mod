@@ -3,10 +3,15 @@ module github.com/aura-studio/nano
 go 1.12
 
 require (
+	github.com/fxamacker/cbor/v2 v2.4.0
 	github.com/golang/protobuf v1.4.2
 	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826
 	github.com/pingcap/check v0.0.0-20190102082844-67f458068fc8
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.10.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	golang.org/x/net v0.0.0-20201202161906-c7110b5ffcbb // indirect
 	google.golang.org/grpc v1.30.0
 	google.golang.org/protobuf v1.25.0
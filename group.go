@@ -21,9 +21,11 @@
 package nano
 
 import (
+	"sort"
 	"sync"
 	"sync/atomic"
 
+	"github.com/aura-studio/nano/cluster"
 	"github.com/aura-studio/nano/env"
 	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/message"
@@ -48,13 +50,48 @@ type Group struct {
 	sessions map[int64]*session.Session // session id map to session instance
 }
 
-// NewGroup returns a new group instance
+var (
+	// groups is the set of groups not closed, which are listed on
+	// "/admin/groups" of the debug address. Groups are tracked only if the
+	// debug address is set, and are kept until closed.
+	groups sync.Map
+
+	// trackGroups is set if groups are tracked
+	trackGroups int32
+)
+
+type groupInfo struct {
+	Name    string  `json:"name"`
+	Count   int     `json:"count"`
+	Members []int64 `json:"members"`
+}
+
+func init() {
+	cluster.RegisterAdminResource("groups", func() interface{} {
+		infos := []groupInfo{}
+		groups.Range(func(key, _ interface{}) bool {
+			g := key.(*Group)
+			infos = append(infos, groupInfo{Name: g.name, Count: g.Count(), Members: g.Members()})
+			return true
+		})
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+		return infos
+	})
+}
+
+// NewGroup returns a new group instance, the group should be closed by Close
+// if it is no longer used when the debug address is set, which is listed by
+// the admin API until closed
 func NewGroup(n string) *Group {
-	return &Group{
+	g := &Group{
 		status:   groupStatusWorking,
 		name:     n,
 		sessions: make(map[int64]*session.Session),
 	}
+	if atomic.LoadInt32(&trackGroups) == 1 {
+		groups.Store(g, struct{}{})
+	}
+	return g
 }
 
 // Member returns specified UID's session
@@ -90,7 +127,7 @@ func (c *Group) Multicast(route string, v interface{}, filter SessionFilter) err
 		return ErrClosedGroup
 	}
 
-	data, err := message.Serialize(v)
+	data, err := message.SerializeRoute(route, v)
 	if err != nil {
 		return err
 	}
@@ -118,7 +155,7 @@ func (c *Group) Broadcast(route string, v interface{}) error {
 		return ErrClosedGroup
 	}
 
-	data, err := message.Serialize(v)
+	data, err := message.SerializeRoute(route, v)
 	if err != nil {
 		return err
 	}
@@ -151,8 +188,7 @@ func (c *Group) Add(session *session.Session) error {
 	}
 
 	if env.Debug {
-		log.Infof("Add session to group %s, ID=%d, UID=%d", c.name,
-			session.ID(), session.UID())
+		session.Logger().Log(log.LevelInfo, "session added to group", "group", c.name)
 	}
 
 	c.mu.Lock()
@@ -175,8 +211,7 @@ func (c *Group) Leave(s *session.Session) error {
 	}
 
 	if env.Debug {
-		log.Infof("Remove session from group %s, UID=%d",
-			c.name, s.UID())
+		s.Logger().Log(log.LevelInfo, "session removed from group", "group", c.name)
 	}
 
 	c.mu.Lock()
@@ -221,8 +256,11 @@ func (c *Group) Close() error {
 	}
 
 	atomic.StoreInt32(&c.status, groupStatusClosed)
+	groups.Delete(c)
 
 	// release all reference
+	c.mu.Lock()
 	c.sessions = make(map[int64]*session.Session)
+	c.mu.Unlock()
 	return nil
 }
@@ -2,6 +2,7 @@ package nano
 
 import (
 	"math/rand"
+	"sync/atomic"
 	"testing"
 
 	"github.com/aura-studio/nano/session"
@@ -40,3 +41,26 @@ func TestChannel_Add(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGroupTracking(t *testing.T) {
+	tracked := func(g *Group) bool {
+		_, ok := groups.Load(g)
+		return ok
+	}
+
+	g := NewGroup("untracked")
+	if tracked(g) {
+		t.Fatal("group is tracked without debug address")
+	}
+
+	atomic.StoreInt32(&trackGroups, 1)
+	defer atomic.StoreInt32(&trackGroups, 0)
+	g = NewGroup("tracked")
+	if !tracked(g) {
+		t.Fatal("group is not tracked")
+	}
+	g.Close()
+	if tracked(g) {
+		t.Fatal("closed group is tracked")
+	}
+}
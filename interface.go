@@ -34,6 +34,7 @@ import (
 	"github.com/aura-studio/nano/env"
 	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/scheduler"
+	"github.com/aura-studio/nano/tracing"
 )
 
 const (
@@ -83,7 +84,16 @@ func Listen(addr string, opts ...Option) {
 		option(&opt)
 	}
 
+	// groups are listed by the admin API of the debug address
+	if opt.DebugAddr != "" {
+		atomic.StoreInt32(&trackGroups, 1)
+	}
+
 	log.SetLogger(opt.Logger)
+	log.SetStructuredLogger(opt.StructuredLogger)
+	if opt.TraceExporter != nil {
+		tracing.SetExporter(opt.TraceExporter)
+	}
 
 	log.Infoln("Nano server is starting...")
 
@@ -154,13 +164,22 @@ func Listen(addr string, opts ...Option) {
 
 	log.Infoln("Nano server is stopping...")
 
-	node.Shutdown()
+	if opt.DrainTimeout > 0 {
+		node.GracefulShutdown(opt.DrainTimeout)
+	} else {
+		node.Shutdown()
+	}
 	scheduler.Close()
+	if opt.TraceExporter != nil {
+		// flush spans
+		tracing.SetExporter(nil)
+	}
 	atomic.StoreInt32(&app.running, 0)
 	log.Infoln("Nano server stopped")
 }
 
-// Shutdown send a signal to let 'nano' shutdown itself.
+// Shutdown send a signal to let 'nano' shutdown itself, sessions are drained
+// before shut down if the drain timeout is set.
 func Shutdown() {
 	close(env.Die)
 }
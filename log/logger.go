@@ -125,6 +125,16 @@ var (
 	Errorln func(v ...interface{})
 )
 
+// outputLogger is implemented by loggers writing with the call depth, such as
+// *log.Logger of the standard library
+type outputLogger interface {
+	Output(calldepth int, s string) error
+}
+
+// output writes the structured logs with the call depth, so that the flags of
+// file and line report the caller instead of the structured logger
+var output func(calldepth int, s string) error
+
 // SetLogger rewrites the default logger
 func SetLogger(logger Logger) {
 	if logger == nil {
@@ -140,6 +150,11 @@ func SetLogger(logger Logger) {
 }
 
 func setLogger(logger Logger) {
+	output = nil
+	if o, ok := logger.(outputLogger); ok {
+		output = o.Output
+	}
+
 	Tracef = logger.Printf
 	Trace = logger.Print
 	Traceln = logger.Println
@@ -178,6 +193,9 @@ func setLogger(logger Logger) {
 }
 
 func setLevelLogger(logger LevelLogger) {
+	// the structured logs are written by the levels of logger
+	output = nil
+
 	Tracef = logger.Tracef
 	Trace = logger.Trace
 	Traceln = logger.Traceln
@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger adapts a *slog.Logger to the StructuredLogger interface,
+// slog.Default() is used if logger is nil
+func NewSlogLogger(logger *slog.Logger) StructuredLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Enabled(level Level) bool {
+	return l.logger.Enabled(context.Background(), slog.Level(level))
+}
+
+func (l *slogLogger) Log(level Level, msg string, kv ...interface{}) {
+	l.logger.Log(context.Background(), slog.Level(level), msg, kv...)
+}
+
+func (l *slogLogger) With(kv ...interface{}) StructuredLogger {
+	return &slogLogger{logger: l.logger.With(kv...)}
+}
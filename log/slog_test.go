@@ -0,0 +1,33 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logger := NewSlogLogger(slog.New(handler)).With("sid", 1)
+	if logger.Enabled(LevelDebug) {
+		t.Fatal("debug logs are enabled")
+	}
+
+	logger.Log(LevelDebug, "ignored")
+	logger.Log(LevelWarn, "message", "route", "Room.Join")
+	if got, want := strings.TrimSpace(buf.String()), `level=WARN msg=message sid=1 route=Room.Join`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+package log
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level is the level of structured logs, the values are the same as log/slog
+type Level int
+
+// Levels of structured logs
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// StructuredLogger represents the structured log interface, kv are alternating
+// keys and values, such as: "route", "Room.Join", "uid", 1001
+type StructuredLogger interface {
+	Enabled(level Level) bool
+	Log(level Level, msg string, kv ...interface{})
+	With(kv ...interface{}) StructuredLogger
+}
+
+// printfLogger writes structured logs by the printf-style logger set by
+// SetLogger, in the format: msg key=value key=value
+type printfLogger struct {
+	kv []interface{}
+}
+
+func (l *printfLogger) Enabled(Level) bool { return true }
+
+func (l *printfLogger) Log(level Level, msg string, kv ...interface{}) {
+	l.LogDepth(1, level, msg, kv...)
+}
+
+// LogDepth writes the log as Log, depth is the number of stack frames skipped
+// above the caller to report the file and line by the logger
+func (l *printfLogger) LogDepth(depth int, level Level, msg string, kv ...interface{}) {
+	var b strings.Builder
+	b.WriteString(msg)
+	appendFields(&b, l.kv)
+	appendFields(&b, kv)
+
+	line := b.String()
+	if output != nil {
+		// skip LogDepth itself and the frames above the caller
+		output(depth+2, line)
+		return
+	}
+	switch {
+	case level < LevelInfo:
+		Debugln(line)
+	case level < LevelWarn:
+		Infoln(line)
+	case level < LevelError:
+		Warnln(line)
+	default:
+		Errorln(line)
+	}
+}
+
+func (l *printfLogger) With(kv ...interface{}) StructuredLogger {
+	return &printfLogger{kv: append(append([]interface{}(nil), l.kv...), kv...)}
+}
+
+func appendFields(b *strings.Builder, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		b.WriteByte(' ')
+		if i+1 == len(kv) {
+			// a dangling value without key
+			fmt.Fprintf(b, "!BADKEY=%v", kv[i])
+			break
+		}
+		s := fmt.Sprint(kv[i+1])
+		if strings.ContainsAny(s, " \t\n\"=") {
+			s = fmt.Sprintf("%q", s)
+		}
+		fmt.Fprintf(b, "%v=%s", kv[i], s)
+	}
+}
+
+// depthLogger is implemented by structured loggers which report the caller by
+// the depth of stack frames
+type depthLogger interface {
+	LogDepth(depth int, level Level, msg string, kv ...interface{})
+}
+
+// LogDepth writes the log by logger as logger.Log, depth is the number of stack
+// frames skipped above the caller to report the file and line, which is used by
+// the helpers wrapping logs, the depth is ignored if logger does not support it
+func LogDepth(logger StructuredLogger, depth int, level Level, msg string, kv ...interface{}) {
+	if l, ok := logger.(depthLogger); ok {
+		l.LogDepth(depth+1, level, msg, kv...)
+		return
+	}
+	logger.Log(level, msg, kv...)
+}
+
+var (
+	muStructured sync.RWMutex
+	structured   StructuredLogger = &printfLogger{}
+)
+
+// SetStructuredLogger rewrites the default structured logger, which writes by
+// the printf-style logger if not set
+func SetStructuredLogger(logger StructuredLogger) {
+	if logger == nil {
+		return
+	}
+	muStructured.Lock()
+	defer muStructured.Unlock()
+	structured = logger
+}
+
+// Structured returns the default structured logger
+func Structured() StructuredLogger {
+	muStructured.RLock()
+	defer muStructured.RUnlock()
+	return structured
+}
+
+// With returns the default structured logger bound with fields
+func With(kv ...interface{}) StructuredLogger {
+	return Structured().With(kv...)
+}
+
+var (
+	sampleRate uint64 // math.Float64bits of rate
+	muRand     sync.Mutex
+	random     = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// SetSampleRate sets the rate of per-message debug logs to be written, which
+// are not written by default
+func SetSampleRate(rate float64) {
+	atomic.StoreUint64(&sampleRate, math.Float64bits(rate))
+}
+
+// SampleRate returns the rate of per-message debug logs to be written
+func SampleRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&sampleRate))
+}
+
+// Sampled reports whether a per-message debug log should be written
+func Sampled() bool {
+	rate := SampleRate()
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 1 {
+		return true
+	}
+	muRand.Lock()
+	defer muRand.Unlock()
+	return random.Float64() < rate
+}
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPrintfLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetLogger(log.New(buf, "", 0))
+	defer SetLogger(log.New(os.Stderr, "", log.LstdFlags|log.Lshortfile))
+
+	logger := With("sid", 1, "uid", 1001)
+	logger.With("route", "Room.Join").Log(LevelInfo, "message", "data", "a b", "dangling")
+	if got, want := strings.TrimSpace(buf.String()), `message sid=1 uid=1001 route=Room.Join data="a b" !BADKEY=dangling`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+// logHelper wraps the structured log as the helpers of callers
+func logHelper(msg string) {
+	LogDepth(Structured(), 1, LevelInfo, msg)
+}
+
+func TestPrintfLoggerCaller(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetLogger(log.New(buf, "", log.Lshortfile))
+	defer SetLogger(log.New(os.Stderr, "", log.LstdFlags|log.Lshortfile))
+
+	_, _, line, _ := runtime.Caller(0)
+	With("sid", 1).Log(LevelDebug, "direct")
+	logHelper("helper")
+	want := fmt.Sprintf("structured_test.go:%d: direct sid=1\nstructured_test.go:%d: helper", line+1, line+2)
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSampled(t *testing.T) {
+	defer SetSampleRate(0)
+
+	if Sampled() {
+		t.Fatal("sampled without rate")
+	}
+	SetSampleRate(1)
+	if !Sampled() {
+		t.Fatal("not sampled with rate 1")
+	}
+
+	SetSampleRate(0.2)
+	n := 0
+	for i := 0; i < 10000; i++ {
+		if Sampled() {
+			n++
+		}
+	}
+	if n < 1500 || n > 2500 {
+		t.Fatalf("%d of 10000 sampled with rate 0.2", n)
+	}
+}
@@ -40,6 +40,7 @@ const (
 
 const (
 	msgRouteNotCompressMask = 0x08
+	msgErrorMask            = 0x10
 	msgTypeMask             = 0x07
 	msgHeadLength           = 0x02
 )
@@ -73,6 +74,7 @@ type Message struct {
 	Route      string // route for locating service
 	Data       []byte // payload
 	Compressed bool   // is message compressed
+	Error      bool   // is response an error, the data is not serialized by the serializer of route
 }
 
 // New returns a new message instance
@@ -107,6 +109,9 @@ func Encode(m *Message, routes map[string]uint16) ([]byte, error) {
 	if !compressed {
 		flag |= msgRouteNotCompressMask
 	}
+	if m.Error {
+		flag |= msgErrorMask
+	}
 	buf[offset] = byte(flag)
 	offset++
 
@@ -149,6 +154,7 @@ func Decode(data []byte, codes map[uint16]string) (*Message, bool, error) {
 	offset++
 	m.Type = Type(flag & msgTypeMask)
 	m.Compressed = flag&msgRouteNotCompressMask == 0
+	m.Error = flag&msgErrorMask != 0
 	if invalidType(m.Type) {
 		return nil, false, ErrWrongMessageType
 	}
@@ -0,0 +1,73 @@
+package message
+
+// Priority represents the send priority of an outgoing message, messages in a
+// higher priority lane are written to the client before messages in a lower one,
+// and messages in the same lane keep their order.
+type Priority byte
+
+// Message priorities
+const (
+	PriorityDefault  Priority = iota // resolved by route, PriorityNormal if not set
+	PriorityBulk                     // low-value pushes, e.g. broadcast
+	PriorityNormal                   // regular pushes
+	PriorityCritical                 // pushes that must not wait behind regular pushes
+	PriorityResponse                 // responses to client requests
+)
+
+// PriorityLanes is the number of lanes a priority can be mapped to.
+const PriorityLanes = int(PriorityResponse)
+
+var priorities = map[Priority]string{
+	PriorityDefault:  "Default",
+	PriorityBulk:     "Bulk",
+	PriorityNormal:   "Normal",
+	PriorityCritical: "Critical",
+	PriorityResponse: "Response",
+}
+
+func (p Priority) String() string {
+	return priorities[p]
+}
+
+// Lane returns the zero-based lane index of the priority, PriorityDefault
+// and unknown priorities are mapped to the PriorityNormal lane.
+func (p Priority) Lane() int {
+	if p == PriorityDefault || p > PriorityResponse {
+		p = PriorityNormal
+	}
+	return int(p) - 1
+}
+
+var (
+	// Priorities is a map from route to priority
+	Priorities = make(map[string]Priority)
+)
+
+// RoutePriority resolves the priority of a route when p is PriorityDefault.
+func RoutePriority(route string, p Priority) Priority {
+	if p != PriorityDefault {
+		return p
+	}
+
+	rw.RLock()
+	defer rw.RUnlock()
+
+	if p, ok := Priorities[route]; ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+// WritePriorityItem is to set priority item of a route.
+func WritePriorityItem(route string, p Priority) map[string]Priority {
+	rw.Lock()
+	defer rw.Unlock()
+
+	if p == PriorityDefault {
+		delete(Priorities, route)
+	} else {
+		Priorities[route] = p
+	}
+
+	return Priorities
+}
@@ -1,63 +1,119 @@
 package message
 
 import (
+	"fmt"
+	"reflect"
+
 	"github.com/aura-studio/nano/env"
 	"github.com/aura-studio/nano/serialize"
+	"github.com/aura-studio/nano/serialize/cbor"
 	"github.com/aura-studio/nano/serialize/json"
+	"github.com/aura-studio/nano/serialize/msgpack"
 	"github.com/aura-studio/nano/serialize/protobuf"
 	"github.com/aura-studio/nano/serialize/rawstring"
 )
 
+// Serializer types of built-in serializers, custom serializers should be
+// registered with a type code which is not less than CustomSerializer
 const (
 	Unknown uint16 = iota
 	JSON
 	Protobuf
 	RawString
-)
+	MessagePack
+	CBOR
 
-var (
-	jsonSerializer      = json.NewSerializer()
-	protobufSerializer  = protobuf.NewSerializer()
-	rawStringSerializer = rawstring.NewSerializer()
+	CustomSerializer uint16 = 1 << 8
 )
 
 var (
 	// Serializers is a map from route to serializer
 	Serializers = make(map[string]serialize.Serializer)
+
+	// registry of serializers, type code to serializer and serializer
+	// type to type code
+	serializerRegistry = make(map[uint16]serialize.Serializer)
+	serializerTypes    = make(map[reflect.Type]uint16)
 )
 
+func init() {
+	registerSerializer(JSON, json.NewSerializer())
+	registerSerializer(Protobuf, protobuf.NewSerializer())
+	registerSerializer(RawString, rawstring.NewSerializer())
+	registerSerializer(MessagePack, msgpack.NewSerializer())
+	registerSerializer(CBOR, cbor.NewSerializer())
+
+	if env.SerializerType == Unknown {
+		env.SerializerType = GetSerializerType(env.Serializer)
+	}
+}
+
+func registerSerializer(typ uint16, s serialize.Serializer) {
+	serializerRegistry[typ] = s
+	serializerTypes[reflect.TypeOf(s)] = typ
+}
+
+// RegisterSerializer registers a custom serializer with a stable type code, the
+// type code is propagated to other cluster members in route dictionary, so all
+// members should register the same serializer with the same type code. It should
+// be called before the server startup.
+func RegisterSerializer(typ uint16, s serialize.Serializer) error {
+	if s == nil {
+		return fmt.Errorf("nil serializer for type %d", typ)
+	}
+	if typ < CustomSerializer {
+		return fmt.Errorf("serializer type %d is reserved for built-in serializers", typ)
+	}
+
+	rw.Lock()
+	defer rw.Unlock()
+
+	if exists, ok := serializerRegistry[typ]; ok && reflect.TypeOf(exists) != reflect.TypeOf(s) {
+		return fmt.Errorf("serializer type %d is already registered by %T", typ, exists)
+	}
+	if exists, ok := serializerTypes[reflect.TypeOf(s)]; ok && exists != typ {
+		return fmt.Errorf("serializer %T is already registered as type %d", s, exists)
+	}
+	registerSerializer(typ, s)
+
+	return nil
+}
+
+// GetSerializerType returns the type code of a registered serializer, and
+// Unknown if the serializer is not registered
 func GetSerializerType(s serialize.Serializer) uint16 {
-	switch s.(type) {
-	case *json.Serializer:
-		return JSON
-	case *protobuf.Serializer:
-		return Protobuf
-	case *rawstring.Serializer:
-		return RawString
-	default:
-		return Unknown
+	rw.RLock()
+	defer rw.RUnlock()
+
+	if typ, ok := serializerTypes[reflect.TypeOf(s)]; ok {
+		return typ
 	}
+	return Unknown
 }
 
+// GetSerializer returns the serializer registered by type code, and the
+// application serializer if the type code is not registered
 func GetSerializer(typ uint16) serialize.Serializer {
-	switch typ {
-	case JSON:
-		return jsonSerializer
-	case Protobuf:
-		return protobufSerializer
-	case RawString:
-		return rawStringSerializer
-	default:
-		return env.Serializer
+	rw.RLock()
+	defer rw.RUnlock()
+
+	return getSerializer(typ)
+}
+
+func getSerializer(typ uint16) serialize.Serializer {
+	if s, ok := serializerRegistry[typ]; ok {
+		return s
 	}
+	return env.Serializer
 }
 
-// ReadSerializers returns serializers for compressed route.
+// ReadSerializers returns a copy of serializers of routes, which can be read
+// without lock.
 func ReadSerializers() map[string]serialize.Serializer {
 	rw.RLock()
 	defer rw.RUnlock()
 
-	return Serializers
+	return copySerializers()
 }
 
 // WriteSerializerItem is to set serializer item when server registers.
@@ -65,9 +121,9 @@ func WriteSerializerItem(route string, typ uint16) map[string]serialize.Serializ
 	rw.Lock()
 	defer rw.Unlock()
 
-	Serializers[route] = GetSerializer(typ)
+	Serializers[route] = getSerializer(typ)
 
-	return Serializers
+	return copySerializers()
 }
 
 // WriteSerializers is to set serializers when new serializer dictionary is found.
@@ -76,10 +132,18 @@ func WriteSerializers(serializers map[string]uint16) map[string]serialize.Serial
 	defer rw.Unlock()
 
 	for route, typ := range serializers {
-		Serializers[route] = GetSerializer(typ)
+		Serializers[route] = getSerializer(typ)
 	}
 
-	return Serializers
+	return copySerializers()
+}
+
+func copySerializers() map[string]serialize.Serializer {
+	serializers := make(map[string]serialize.Serializer, len(Serializers))
+	for route, s := range Serializers {
+		serializers[route] = s
+	}
+	return serializers
 }
 
 func Serialize(v interface{}) ([]byte, error) {
@@ -107,3 +171,18 @@ func RouteSerialize(serializers map[string]serialize.Serializer, route string, v
 	}
 	return data, nil
 }
+
+// SerializeRoute serializes v by the serializer of route, the application
+// serializer is used if the route has no serializer
+func SerializeRoute(route string, v interface{}) ([]byte, error) {
+	if data, ok := v.([]byte); ok {
+		return data, nil
+	}
+	rw.RLock()
+	serializer, ok := Serializers[route]
+	rw.RUnlock()
+	if !ok {
+		serializer = env.Serializer
+	}
+	return serializer.Marshal(v)
+}
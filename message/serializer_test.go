@@ -0,0 +1,81 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/aura-studio/nano/serialize/cbor"
+	"github.com/aura-studio/nano/serialize/json"
+	"github.com/aura-studio/nano/serialize/msgpack"
+)
+
+type customSerializer struct{ json.Serializer }
+
+type otherSerializer struct{ json.Serializer }
+
+func TestGetSerializerType(t *testing.T) {
+	if typ := GetSerializerType(msgpack.NewSerializer()); typ != MessagePack {
+		t.Fatalf("expect: %d, got: %d", MessagePack, typ)
+	}
+	if typ := GetSerializerType(cbor.NewSerializer()); typ != CBOR {
+		t.Fatalf("expect: %d, got: %d", CBOR, typ)
+	}
+	if typ := GetSerializerType(&otherSerializer{}); typ != Unknown {
+		t.Fatalf("expect: %d, got: %d", Unknown, typ)
+	}
+}
+
+func TestRegisterSerializer(t *testing.T) {
+	if err := RegisterSerializer(CBOR, &customSerializer{}); err == nil {
+		t.Fatal("expect error while registering a built-in type code")
+	}
+
+	typ := CustomSerializer + 1
+	if err := RegisterSerializer(typ, &customSerializer{}); err != nil {
+		t.Fatal(err)
+	}
+	// registering the same serializer again is allowed
+	if err := RegisterSerializer(typ, &customSerializer{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterSerializer(typ, &otherSerializer{}); err == nil {
+		t.Fatal("expect error while registering a type code twice")
+	}
+	if err := RegisterSerializer(typ+1, &customSerializer{}); err == nil {
+		t.Fatal("expect error while registering a serializer twice")
+	}
+
+	if got := GetSerializerType(&customSerializer{}); got != typ {
+		t.Fatalf("expect: %d, got: %d", typ, got)
+	}
+	if _, ok := GetSerializer(typ).(*customSerializer); !ok {
+		t.Fatalf("expect custom serializer, got: %T", GetSerializer(typ))
+	}
+
+	WriteSerializers(map[string]uint16{"custom.route": typ})
+	if _, ok := ReadSerializers()["custom.route"].(*customSerializer); !ok {
+		t.Fatalf("expect custom serializer for route")
+	}
+}
+
+func TestSerializeRoute(t *testing.T) {
+	WriteSerializers(map[string]uint16{"json.route": JSON})
+	data, err := SerializeRoute("json.route", map[string]string{"content": "hello"})
+	if err != nil || string(data) != `{"content":"hello"}` {
+		t.Fatalf("unexpected data: %s, %v", data, err)
+	}
+
+	// serializers read by agents are not written by cluster members
+	serializers := ReadSerializers()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			WriteSerializers(map[string]uint16{"json.route": JSON})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = serializers["json.route"]
+		SerializeRoute("json.route", []byte("raw"))
+	}
+	<-done
+}
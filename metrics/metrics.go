@@ -0,0 +1,359 @@
+// Package metrics implements counters, gauges and histograms exposed in the
+// Prometheus text format, which are served on the debug address of nodes.
+//
+// It only needs a few metric types and the text exposition format, so it does
+// not depend on prometheus/client_golang, whose recent releases require newer
+// Go versions than nano, the output is tested by the Prometheus text parser.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are the default histogram buckets in seconds
+var DefBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector writes metrics in the Prometheus text format
+type Collector interface {
+	Collect(w io.Writer) error
+}
+
+// Registry is a set of collectors
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+// NewRegistry returns a registry with collectors
+func NewRegistry(collectors ...Collector) *Registry {
+	return &Registry{collectors: collectors}
+}
+
+// Register adds collectors to the registry
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Collect writes all metrics of registry
+func (r *Registry) Collect(w io.Writer) error {
+	r.mu.RLock()
+	collectors := r.collectors
+	r.mu.RUnlock()
+
+	for _, c := range collectors {
+		if err := c.Collect(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP implements the http.Handler interface
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	if err := r.Collect(bw); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	bw.Flush()
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escape(d.help, false), d.name, d.typ)
+	return err
+}
+
+// pairs formats the labels of a series, extra label pairs are appended
+func (d *desc) pairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l + `="` + escape(values[i], true) + `"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i] + `="` + escape(extra[i+1], true) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escape(s string, quote bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	if quote {
+		s = strings.Replace(s, `"`, `\"`, -1)
+	}
+	return s
+}
+
+func format(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// value is a float64 updated atomically
+type value struct{ bits uint64 }
+
+func (v *value) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		n := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&v.bits, old, n) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64) { atomic.StoreUint64(&v.bits, math.Float64bits(f)) }
+func (v *value) get() float64  { return math.Float64frombits(atomic.LoadUint64(&v.bits)) }
+
+// vec is a set of series keyed by label values
+type vec struct {
+	desc
+	mu     sync.RWMutex
+	series map[string]interface{}
+	values map[string][]string
+	create func() interface{}
+}
+
+func newVec(name, help, typ string, labels []string, create func() interface{}) vec {
+	return vec{
+		desc:   desc{name: name, help: help, typ: typ, labels: labels},
+		series: make(map[string]interface{}),
+		values: make(map[string][]string),
+		create: create,
+	}
+}
+
+func (v *vec) get(values []string) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s
+	}
+	s = v.create()
+	v.series[key] = s
+	v.values[key] = append([]string(nil), values...)
+	return s
+}
+
+// each calls fn with the series sorted by label values
+func (v *vec) each(fn func(values []string, s interface{}) error) error {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	v.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.mu.RLock()
+		s, values := v.series[key], v.values[key]
+		v.mu.RUnlock()
+		if err := fn(values, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *vec) collect(w io.Writer) error {
+	if err := v.header(w); err != nil {
+		return err
+	}
+	return v.each(func(values []string, s interface{}) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", v.name, v.pairs(values), format(s.(*value).get()))
+		return err
+	})
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct{ vec }
+
+// NewCounterVec returns a counter with label names
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, "counter", labels, func() interface{} { return &value{} })}
+}
+
+// Inc increases the counter of label values by one
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add increases the counter of label values by delta, which must not be negative
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.get(values).(*value).add(delta)
+}
+
+// Value returns the counter of label values
+func (c *CounterVec) Value(values ...string) float64 {
+	return c.get(values).(*value).get()
+}
+
+// Collect implements the Collector interface
+func (c *CounterVec) Collect(w io.Writer) error {
+	return c.collect(w)
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct{ vec }
+
+// NewGaugeVec returns a gauge with label names
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newVec(name, help, "gauge", labels, func() interface{} { return &value{} })}
+}
+
+// Set sets the gauge of label values
+func (g *GaugeVec) Set(v float64, values ...string) {
+	g.get(values).(*value).set(v)
+}
+
+// Add adds delta to the gauge of label values, delta can be negative
+func (g *GaugeVec) Add(delta float64, values ...string) {
+	g.get(values).(*value).add(delta)
+}
+
+// Value returns the gauge of label values
+func (g *GaugeVec) Value(values ...string) float64 {
+	return g.get(values).(*value).get()
+}
+
+// Collect implements the Collector interface
+func (g *GaugeVec) Collect(w io.Writer) error {
+	return g.collect(w)
+}
+
+// GaugeFunc is a gauge whose value is read by a function at collecting
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc returns a gauge reading value by fn
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{desc: desc{name: name, help: help, typ: "gauge"}, fn: fn}
+}
+
+// Collect implements the Collector interface
+func (g *GaugeFunc) Collect(w io.Writer) error {
+	if err := g.header(w); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", g.name, format(g.fn()))
+	return err
+}
+
+type histogram struct {
+	counts []uint64 // count of each bucket, the last one is +Inf
+	count  uint64
+	sum    value
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec returns a histogram with upper bounds of buckets and label
+// names, DefBuckets is used if buckets is empty
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{
+		vec: newVec(name, help, "histogram", labels, func() interface{} {
+			return &histogram{counts: make([]uint64, len(buckets)+1)}
+		}),
+		buckets: buckets,
+	}
+}
+
+// Observe adds an observation to the histogram of label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	s := h.get(values).(*histogram)
+	i := sort.SearchFloat64s(h.buckets, v)
+	atomic.AddUint64(&s.counts[i], 1)
+	atomic.AddUint64(&s.count, 1)
+	s.sum.add(v)
+}
+
+// Count returns the count of observations of label values
+func (h *HistogramVec) Count(values ...string) uint64 {
+	return atomic.LoadUint64(&h.get(values).(*histogram).count)
+}
+
+// Collect implements the Collector interface
+func (h *HistogramVec) Collect(w io.Writer) error {
+	if err := h.header(w); err != nil {
+		return err
+	}
+	return h.each(func(values []string, s interface{}) error {
+		hist := s.(*histogram)
+		var cumulative uint64
+		for i := range hist.counts {
+			cumulative += atomic.LoadUint64(&hist.counts[i])
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.pairs(values, "le", format(le)), cumulative); err != nil {
+				return err
+			}
+		}
+		labels := h.pairs(values)
+		_, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, labels, format(hist.sum.get()),
+			h.name, labels, cumulative)
+		return err
+	})
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestRegistry(t *testing.T) {
+	counter := NewCounterVec("test_messages_total", "Number of messages.", "type", "route")
+	counter.Inc("Request", "Room.Join")
+	counter.Add(2, "Request", "Room.Join")
+	counter.Inc("Notify", `Room."Say"`)
+	if v := counter.Value("Request", "Room.Join"); v != 3 {
+		t.Fatalf("unexpected counter: %v", v)
+	}
+
+	gauge := NewGaugeVec("test_queue_depth", "Depth of queue.", "lane")
+	gauge.Add(3, "Normal")
+	gauge.Add(-1, "Normal")
+
+	hist := NewHistogramVec("test_duration_seconds", "Latency.", []float64{0.1, 1}, "route")
+	hist.Observe(0.05, "Room.Join")
+	hist.Observe(0.5, "Room.Join")
+	hist.Observe(5, "Room.Join")
+	if n := hist.Count("Room.Join"); n != 3 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+
+	r := NewRegistry(counter, gauge, NewGaugeFunc("test_sessions", "Sessions.", func() float64 { return 7 }))
+	r.Register(hist)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	expect := `# HELP test_messages_total Number of messages.
+# TYPE test_messages_total counter
+test_messages_total{type="Notify",route="Room.\"Say\""} 1
+test_messages_total{type="Request",route="Room.Join"} 3
+# HELP test_queue_depth Depth of queue.
+# TYPE test_queue_depth gauge
+test_queue_depth{lane="Normal"} 2
+# HELP test_sessions Sessions.
+# TYPE test_sessions gauge
+test_sessions 7
+# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="Room.Join",le="0.1"} 1
+test_duration_seconds_bucket{route="Room.Join",le="1"} 2
+test_duration_seconds_bucket{route="Room.Join",le="+Inf"} 3
+test_duration_seconds_sum{route="Room.Join"} 5.55
+test_duration_seconds_count{route="Room.Join"} 3
+`
+	if got := w.Body.String(); got != expect {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type: %s", ct)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Default.Collect(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"nano_sessions", "nano_messages_total", "nano_handler_duration_seconds",
+		"nano_scheduler_queue_depth", "nano_agent_send_queue_depth", "nano_forward_duration_seconds", "nano_timers"} {
+		if !strings.Contains(buf.String(), "# TYPE "+name+" ") {
+			t.Fatalf("metric %s not found", name)
+		}
+	}
+}
+
+// TestTextFormat parses the output by the text parser of Prometheus
+func TestTextFormat(t *testing.T) {
+	counter := NewCounterVec("test_conformance_total", "Number of \\ messages\nin total.", "route")
+	counter.Inc("Room.\"Say\"\\\n")
+	gauge := NewGaugeFunc("test_conformance_inf", "Infinite.", func() float64 { return math.Inf(1) })
+	hist := NewHistogramVec("test_conformance_seconds", "Latency.", []float64{0.1, 1}, "route")
+	hist.Observe(0.5, "Room.Join")
+	hist.Observe(5, "Room.Join")
+
+	buf := &bytes.Buffer{}
+	if err := NewRegistry(counter, gauge, hist).Collect(buf); err != nil {
+		t.Fatal(err)
+	}
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(buf)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, buf)
+	}
+
+	f := families["test_conformance_total"]
+	if f.GetType() != dto.MetricType_COUNTER || f.GetHelp() != "Number of \\ messages\nin total." {
+		t.Fatalf("unexpected family: %v", f)
+	}
+	m := f.GetMetric()[0]
+	if m.GetLabel()[0].GetValue() != "Room.\"Say\"\\\n" || m.GetCounter().GetValue() != 1 {
+		t.Fatalf("unexpected metric: %v", m)
+	}
+
+	if v := families["test_conformance_inf"].GetMetric()[0].GetGauge().GetValue(); !math.IsInf(v, 1) {
+		t.Fatalf("unexpected gauge: %v", v)
+	}
+
+	h := families["test_conformance_seconds"].GetMetric()[0].GetHistogram()
+	if h.GetSampleCount() != 2 || h.GetSampleSum() != 5.5 || len(h.GetBucket()) != 3 {
+		t.Fatalf("unexpected histogram: %v", h)
+	}
+	for i, want := range []uint64{0, 1, 2} {
+		if n := h.GetBucket()[i].GetCumulativeCount(); n != want {
+			t.Fatalf("bucket %d counts %d", i, n)
+		}
+	}
+
+	// metrics of nano
+	buf.Reset()
+	if err := Default.Collect(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&expfmt.TextParser{}).TextToMetricFamilies(buf); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"github.com/aura-studio/nano/scheduler"
+	"github.com/aura-studio/nano/service"
+)
+
+// Directions of messages
+const (
+	In  = "in"
+	Out = "out"
+)
+
+// Metrics of nano
+var (
+	// Messages counts messages received from and sent to clients on the gate,
+	// and messages received from and sent to gates on the backends
+	Messages = NewCounterVec("nano_messages_total",
+		"Number of messages by direction, type and route.", "direction", "type", "route")
+
+	// HandlerDuration observes the latency of local handlers
+	HandlerDuration = NewHistogramVec("nano_handler_duration_seconds",
+		"Latency of handlers in seconds.", nil, "route")
+
+	// SendQueueDepth is the count of messages waiting to be written to clients
+	SendQueueDepth = NewGaugeVec("nano_agent_send_queue_depth",
+		"Number of messages in agent send queues by priority lane.", "lane")
+
+	// ForwardDuration observes the latency of forwarding messages to members
+	ForwardDuration = NewHistogramVec("nano_forward_duration_seconds",
+		"Latency of forwarding messages to cluster members in seconds.", nil, "member")
+
+	// RateLimited counts messages limited on the gate
+	RateLimited = NewCounterVec("nano_rate_limited_total",
+		"Number of messages limited by key, action and route.", "key", "action", "route")
+
+	// Rejected counts client connections rejected by admission control
+	Rejected = NewCounterVec("nano_connections_rejected_total",
+		"Number of client connections rejected by reason.", "reason")
+
+	// Replayed counts messages rejected by replay protection on the gate
+	Replayed = NewCounterVec("nano_messages_replayed_total",
+		"Number of messages rejected by replay protection by reason.", "reason")
+
+	// Default is the registry served on the debug address
+	Default = NewRegistry(
+		NewGaugeFunc("nano_sessions", "Number of connected sessions.", func() float64 {
+			return float64(service.Connections.Count())
+		}),
+		Messages,
+		HandlerDuration,
+		NewGaugeFunc("nano_scheduler_queue_depth", "Number of tasks waiting in scheduler.", func() float64 {
+			return float64(scheduler.QueueDepth())
+		}),
+		SendQueueDepth,
+		ForwardDuration,
+		RateLimited,
+		Rejected,
+		Replayed,
+		NewGaugeFunc("nano_timers", "Number of active timers.", func() float64 {
+			return float64(scheduler.TimerCount())
+		}),
+	)
+)
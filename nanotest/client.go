@@ -0,0 +1,155 @@
+package nanotest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano/connector"
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/pipeline"
+)
+
+// DefaultTimeout is the default timeout of client calls and push expectations
+var DefaultTimeout = 5 * time.Second
+
+// Client is a test client connected to a node, pushes without callbacks
+// registered by On are recorded for expectations
+type Client struct {
+	*connector.Connector
+	t       testing.TB
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	pushes []*message.Message
+	notify chan struct{}
+}
+
+func newClient(t testing.TB, c *connector.Connector) *Client {
+	client := &Client{
+		Connector: c,
+		t:         t,
+		Timeout:   DefaultTimeout,
+		notify:    make(chan struct{}, 1),
+	}
+	c.OnUnexpectedEvent(client.record)
+	return client
+}
+
+func (c *Client) record(data interface{}) {
+	msg, ok := data.(*message.Message)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.pushes = append(c.pushes, msg)
+	c.mu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Call requests the route and deserializes the response into resp, a *[]byte
+// receives the raw data
+func (c *Client) Call(route string, req, resp interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	return c.RequestSync(ctx, route, req, resp)
+}
+
+// MustCall is like Call but fails the test on error
+func (c *Client) MustCall(route string, req, resp interface{}) {
+	c.t.Helper()
+	if err := c.Call(route, req, resp); err != nil {
+		c.t.Fatalf("nanotest: call %s failed: %v", route, err)
+	}
+}
+
+// MustCallError requests the route and fails the test unless the server
+// responds an error
+func (c *Client) MustCallError(route string, req interface{}) *pipeline.Error {
+	c.t.Helper()
+	err := c.Call(route, req, nil)
+	e, ok := err.(*pipeline.Error)
+	if !ok {
+		c.t.Fatalf("nanotest: call %s expects error response, got: %v", route, err)
+	}
+	return e
+}
+
+// MustNotify notifies the route and fails the test on error
+func (c *Client) MustNotify(route string, req interface{}) {
+	c.t.Helper()
+	if err := c.Notify(route, req); err != nil {
+		c.t.Fatalf("nanotest: notify %s failed: %v", route, err)
+	}
+}
+
+// ExpectPush waits for a push of route and deserializes it into v, a *[]byte
+// receives the raw data, the test fails if no push arrives in timeout. Pushes
+// are consumed by the order received.
+func (c *Client) ExpectPush(route string, v interface{}) {
+	c.t.Helper()
+
+	timer := time.NewTimer(c.Timeout)
+	defer timer.Stop()
+	for {
+		if msg := c.take(route); msg != nil {
+			if v == nil {
+				return
+			}
+			if raw, ok := v.(*[]byte); ok {
+				*raw = msg.Data
+				return
+			}
+			if err := c.Deserialize(msg.Data, v); err != nil {
+				c.t.Fatalf("nanotest: deserialize push %s failed: %v", route, err)
+			}
+			return
+		}
+
+		select {
+		case <-c.notify:
+		case <-timer.C:
+			c.t.Fatalf("nanotest: push %s not received in %v", route, c.Timeout)
+			return
+		}
+	}
+}
+
+// ExpectNoPush fails the test if a push of route arrives in duration
+func (c *Client) ExpectNoPush(route string, d time.Duration) {
+	c.t.Helper()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		if msg := c.take(route); msg != nil {
+			c.t.Fatalf("nanotest: unexpected push %s", route)
+			return
+		}
+
+		select {
+		case <-c.notify:
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+// take removes and returns the first push of route
+func (c *Client) take(route string) *message.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, msg := range c.pushes {
+		if msg.Route == route {
+			c.pushes = append(c.pushes[:i], c.pushes[i+1:]...)
+			return msg
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package nanotest
+
+import (
+	"time"
+
+	"github.com/aura-studio/nano/scheduler"
+)
+
+// Clock is a fake clock for scheduler timers, time only moves by Advance
+//
+//	clock := nanotest.NewClock(time.Now())
+//	scheduler.SetClock(clock)
+//	defer scheduler.SetClock(nil)
+//	clock.Advance(24 * time.Hour)
+type Clock = scheduler.FakeClock
+
+// NewClock returns a fake clock starts at now
+func NewClock(now time.Time) *Clock {
+	return scheduler.NewFakeClock(now)
+}
@@ -0,0 +1,75 @@
+// Package nanotest starts nano nodes in process for tests, nodes of a cluster
+// communicate over an in-memory network and clients connect to nodes over
+// in-memory pipes, so multi-node flows can be tested without ports.
+//
+//	c := nanotest.NewCluster(t)
+//	defer c.Close()
+//	c.AddMaster(nano.WithComponents(master))
+//	gate := c.AddNode(nano.WithComponents(gateComps))
+//	c.AddNode(nano.WithComponents(gameComps))
+//
+//	client := gate.Connect()
+//	client.MustCall("Game.Join", &JoinRequest{}, &JoinResponse{})
+//	client.ExpectPush("onJoin", &JoinPush{})
+package nanotest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufferSize = 1 << 20
+
+// Network is an in-memory network of service addresses
+type Network struct {
+	mu        sync.Mutex
+	listeners map[string]*bufconn.Listener
+}
+
+// NewNetwork returns an empty in-memory network
+func NewNetwork() *Network {
+	return &Network{listeners: make(map[string]*bufconn.Listener)}
+}
+
+// Listen listens on the address in network
+func (n *Network) Listen(addr string) (net.Listener, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.listeners[addr]; ok {
+		return nil, fmt.Errorf("nanotest: address %s already in use", addr)
+	}
+	l := bufconn.Listen(bufferSize)
+	n.listeners[addr] = l
+	return &listener{Listener: l, addr: addr, network: n}, nil
+}
+
+// Dial connects to the address in network
+func (n *Network) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	n.mu.Lock()
+	l, ok := n.listeners[addr]
+	n.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("nanotest: connection refused: %s", addr)
+	}
+	return l.Dial()
+}
+
+// listener removes the address from network once closed
+type listener struct {
+	*bufconn.Listener
+	addr    string
+	network *Network
+}
+
+func (l *listener) Close() error {
+	l.network.mu.Lock()
+	delete(l.network.listeners, l.addr)
+	l.network.mu.Unlock()
+	return l.Listener.Close()
+}
@@ -0,0 +1,102 @@
+package nanotest
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano"
+	"github.com/aura-studio/nano/benchmark/testdata"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/scheduler"
+	"github.com/aura-studio/nano/session"
+)
+
+type (
+	GateComponent struct{ component.Base }
+	GameComponent struct{ component.Base }
+)
+
+func (c *GateComponent) Ping(s *session.Session, ping *testdata.Ping) error {
+	return s.Response("GateComponent.Ping", &testdata.Pong{Content: "gate:" + ping.Content})
+}
+
+func (c *GameComponent) Ping(s *session.Session, ping *testdata.Ping) error {
+	if err := s.Push("onPing", &testdata.Pong{Content: "push:" + ping.Content}); err != nil {
+		return err
+	}
+	return s.Response("GameComponent.Ping", &testdata.Pong{Content: "game:" + ping.Content})
+}
+
+func TestServer(t *testing.T) {
+	comps := &component.Components{}
+	comps.Register(&GameComponent{})
+	s := NewServer(t, nano.WithComponents(comps))
+	defer s.Close()
+
+	client := s.Connect()
+	defer client.Close()
+
+	pong := &testdata.Pong{}
+	client.MustCall("GameComponent.Ping", &testdata.Ping{Content: "hello"}, pong)
+	if pong.Content != "game:hello" {
+		t.Fatalf("unexpected response: %v", pong)
+	}
+	client.ExpectPush("onPing", pong)
+	if pong.Content != "push:hello" {
+		t.Fatalf("unexpected push: %v", pong)
+	}
+	client.ExpectNoPush("onPing", 10*time.Millisecond)
+}
+
+func TestCluster(t *testing.T) {
+	c := NewCluster(t)
+	defer c.Close()
+
+	gateComps, gameComps := &component.Components{}, &component.Components{}
+	gateComps.Register(&GateComponent{})
+	gameComps.Register(&GameComponent{})
+
+	c.AddMaster()
+	gate := c.AddNode(nano.WithComponents(gateComps))
+	c.AddNode(nano.WithComponents(gameComps))
+
+	client := gate.Connect()
+	defer client.Close()
+
+	pong := &testdata.Pong{}
+	client.MustCall("GateComponent.Ping", &testdata.Ping{Content: "a"}, pong)
+	if pong.Content != "gate:a" {
+		t.Fatalf("unexpected response: %v", pong)
+	}
+
+	// forwarded to the game node
+	client.MustCall("GameComponent.Ping", &testdata.Ping{Content: "b"}, pong)
+	if pong.Content != "game:b" {
+		t.Fatalf("unexpected response: %v", pong)
+	}
+	client.ExpectPush("onPing", pong)
+	if pong.Content != "push:b" {
+		t.Fatalf("unexpected push: %v", pong)
+	}
+}
+
+func TestClock(t *testing.T) {
+	go scheduler.Digest()
+
+	clock := NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	scheduler.SetClock(clock)
+	defer scheduler.SetClock(nil)
+
+	var fired int32
+	scheduler.NewAfterTimer(24*time.Hour, func() { atomic.AddInt32(&fired, 1) })
+
+	clock.Advance(23 * time.Hour)
+	if n := atomic.LoadInt32(&fired); n != 0 {
+		t.Fatalf("timer fired %d times before due", n)
+	}
+	clock.Advance(time.Hour)
+	if n := atomic.LoadInt32(&fired); n != 1 {
+		t.Fatalf("timer fired %d times, expect once", n)
+	}
+}
@@ -0,0 +1,140 @@
+package nanotest
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano"
+	"github.com/aura-studio/nano/cluster"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/connector"
+	"github.com/aura-studio/nano/env"
+	"github.com/aura-studio/nano/scheduler"
+)
+
+// Server is a node started in process
+type Server struct {
+	*cluster.Node
+	t testing.TB
+}
+
+// NewServer starts a singleton node with options, such as: nano.WithComponents
+func NewServer(t testing.TB, opts ...nano.Option) *Server {
+	t.Helper()
+	return startServer(t, "nanotest", opts...)
+}
+
+func startServer(t testing.TB, addr string, opts ...nano.Option) *Server {
+	t.Helper()
+
+	opt := cluster.Options{
+		Components:    &component.Components{},
+		RetryInterval: 10 * time.Millisecond,
+	}
+	for _, option := range opts {
+		option(&opt)
+	}
+
+	// handlers and timers are scheduled in the global scheduler
+	go scheduler.Digest()
+
+	node := &cluster.Node{Options: opt, ServiceAddr: addr}
+	if err := node.Startup(); err != nil {
+		t.Fatalf("nanotest: start node %s failed: %v", addr, err)
+	}
+	return &Server{Node: node, t: t}
+}
+
+// Connect returns a client connected to the node over in-memory pipes, the
+// client uses the application serializer unless overridden by options
+func (s *Server) Connect(opts ...connector.Option) *Client {
+	s.t.Helper()
+
+	dialer := func(addr string, timeout time.Duration) (net.Conn, error) {
+		client, server := net.Pipe()
+		go s.ServeConn(server)
+		return client, nil
+	}
+	opts = append([]connector.Option{
+		connector.WithSerializer(env.Serializer),
+		connector.WithDialer(dialer),
+	}, opts...)
+
+	c := newClient(s.t, connector.NewConnector(opts...))
+	if err := c.Start(s.ServiceAddr); err != nil {
+		s.t.Fatalf("nanotest: connect failed: %v", err)
+	}
+	return c
+}
+
+// Close shuts down the node
+func (s *Server) Close() {
+	s.Shutdown()
+}
+
+// Cluster is a master and member nodes started in process, which communicate
+// over an in-memory network
+type Cluster struct {
+	t       testing.TB
+	network *Network
+	master  *Server
+	nodes   []*Server
+}
+
+// NewCluster returns an empty cluster, the master should be added first
+func NewCluster(t testing.TB) *Cluster {
+	return &Cluster{t: t, network: NewNetwork()}
+}
+
+func (c *Cluster) networkOption(opt *cluster.Options) {
+	opt.ServiceListener = c.network.Listen
+	opt.ServiceDialer = c.network.Dial
+}
+
+// AddMaster starts the master node with options
+func (c *Cluster) AddMaster(opts ...nano.Option) *Server {
+	c.t.Helper()
+	if c.master != nil {
+		c.t.Fatal("nanotest: master already added")
+	}
+
+	opts = append(opts, nano.WithMaster(), c.networkOption)
+	c.master = startServer(c.t, "master", opts...)
+	return c.master
+}
+
+// AddNode starts a member node with options, which registers to the master
+func (c *Cluster) AddNode(opts ...nano.Option) *Server {
+	c.t.Helper()
+	if c.master == nil {
+		c.t.Fatal("nanotest: master should be added before nodes")
+	}
+
+	addr := fmt.Sprintf("node-%d", len(c.nodes)+1)
+	opts = append(opts, nano.WithAdvertiseAddr(c.master.ServiceAddr, 10*time.Millisecond), c.networkOption)
+	node := startServer(c.t, addr, opts...)
+	c.nodes = append(c.nodes, node)
+	return node
+}
+
+// Master returns the master node
+func (c *Cluster) Master() *Server {
+	return c.master
+}
+
+// Nodes returns the member nodes by the order added
+func (c *Cluster) Nodes() []*Server {
+	return c.nodes
+}
+
+// Close shuts down member nodes in reverse order, and the master at last
+func (c *Cluster) Close() {
+	for i := len(c.nodes) - 1; i >= 0; i-- {
+		c.nodes[i].Close()
+	}
+	if c.master != nil {
+		c.master.Close()
+	}
+}
@@ -1,8 +1,11 @@
 package nano
 
 import (
+	"net/http"
 	"time"
 
+	"github.com/aura-studio/nano/admission"
+	"github.com/aura-studio/nano/auth"
 	"github.com/aura-studio/nano/cluster"
 	"github.com/aura-studio/nano/component"
 	"github.com/aura-studio/nano/env"
@@ -10,7 +13,10 @@ import (
 	"github.com/aura-studio/nano/message"
 	"github.com/aura-studio/nano/persistence"
 	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/ratelimit"
+	"github.com/aura-studio/nano/replay"
 	"github.com/aura-studio/nano/serialize"
+	"github.com/aura-studio/nano/tracing"
 	"github.com/aura-studio/nano/upgrader"
 	"google.golang.org/grpc"
 )
@@ -52,13 +58,83 @@ func WithClientAddr(addr string) Option {
 	}
 }
 
-// WithDebugAddr works with debug http addr
+// WithDebugAddr works with debug http addr, groups created after Listen is
+// called are listed by the admin API and must be closed by Group.Close
 func WithDebugAddr(addr string) Option {
 	return func(opt *cluster.Options) {
 		opt.DebugAddr = addr
 	}
 }
 
+// WithDrainTimeout makes the node drain before shutting down, which rejects new
+// clients, stops the master routing new sessions to the node and waits for
+// sessions to finish until the timeout expires
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(opt *cluster.Options) {
+		opt.DrainTimeout = timeout
+	}
+}
+
+// WithDrainNotice pushes v on the route to clients when the gate starts draining,
+// such as a "server migrating" event to let clients reconnect to other gates
+func WithDrainNotice(route string, v interface{}) Option {
+	return func(opt *cluster.Options) {
+		opt.DrainRoute = route
+		opt.DrainMessage = v
+	}
+}
+
+// WithAdminAuth enables operations of the admin API on the debug address, which
+// are authorized by fn, such as cluster.AdminBearerToken(token)
+func WithAdminAuth(fn func(r *http.Request) bool) Option {
+	return func(opt *cluster.Options) {
+		opt.AdminAuth = fn
+	}
+}
+
+// WithVersionPolicies sets the version policies of the gate, which route
+// unversioned sessions to members of versions by UID allowlists and percentages
+func WithVersionPolicies(policies ...cluster.VersionPolicy) Option {
+	return func(opt *cluster.Options) {
+		opt.VersionPolicies = policies
+	}
+}
+
+// WithRateLimit limits messages from clients on the gate by rules, such as
+// ratelimit.Rule{Key: ratelimit.BySession, Rate: 20, Burst: 40}, the node fails
+// to start up if a rule is invalid
+func WithRateLimit(rules ...ratelimit.Rule) Option {
+	return func(opt *cluster.Options) {
+		opt.RateLimits = append([]ratelimit.Rule(nil), rules...)
+	}
+}
+
+// WithAdmission controls client connections accepted by the gate, such as
+// admission.Config{MaxSessions: 10000, MaxConnsPerIP: 16}
+func WithAdmission(config admission.Config) Option {
+	return func(opt *cluster.Options) {
+		opt.Admission = &config
+	}
+}
+
+// WithAuth authenticates clients on the gate, routes not marked by
+// component.WithAnonymousRoutes are rejected before the session is bound to a
+// UID, by handshake, login or component handlers
+func WithAuth(config auth.Config) Option {
+	return func(opt *cluster.Options) {
+		opt.Auth = &config
+	}
+}
+
+// WithReplayProtection rejects replayed requests on the gate, and verifies
+// signatures of messages from sessions with a secret set by replay.SetSecret,
+// which clients sign by connector.Connector.SetSecret
+func WithReplayProtection(config replay.Config) Option {
+	return func(opt *cluster.Options) {
+		opt.Replay = &config
+	}
+}
+
 // WithMaster sets the option to indicate whether the current node is master node
 func WithMaster() Option {
 	return func(opt *cluster.Options) {
@@ -107,7 +183,8 @@ func WithTimerPrecision(precision time.Duration) Option {
 }
 
 // WithSerializer customizes application serializer, which automatically Marshal
-// and UnMarshal handler payload
+// and UnMarshal handler payload. Custom serializers should be registered by
+// message.RegisterSerializer before, so that cluster members can recognize it
 func WithSerializer(serializer serialize.Serializer) Option {
 	return func(opt *cluster.Options) {
 		env.Serializer = serializer
@@ -115,6 +192,14 @@ func WithSerializer(serializer serialize.Serializer) Option {
 	}
 }
 
+// WithRoutePriority sets the send priority of pushes on the route, pushes without
+// an explicit priority are queued in the priority lane of their route
+func WithRoutePriority(route string, priority message.Priority) Option {
+	return func(_ *cluster.Options) {
+		message.WritePriorityItem(route, priority)
+	}
+}
+
 // WithLabel sets the current node label in cluster
 func WithLabel(label string) Option {
 	return func(opt *cluster.Options) {
@@ -165,3 +250,28 @@ func WithLogger(l log.Logger) Option {
 		opt.Logger = l
 	}
 }
+
+// WithStructuredLogger overrides the default structured logger, such as
+// log.NewSlogLogger(slog.Default())
+func WithStructuredLogger(l log.StructuredLogger) Option {
+	return func(opt *cluster.Options) {
+		opt.StructuredLogger = l
+	}
+}
+
+// WithLogSampleRate sets the rate of per-message debug logs to be written, all
+// messages are logged in debug mode regardless of the rate, the logs are
+// written at the info level as former versions
+func WithLogSampleRate(rate float64) Option {
+	return func(opt *cluster.Options) {
+		opt.LogSampleRate = rate
+	}
+}
+
+// WithTracing enables tracing and exports spans by exporter, such as
+// tracing.NewStdoutExporter(nil) or tracing.NewOTLPExporter("", "gate")
+func WithTracing(exporter tracing.Exporter) Option {
+	return func(opt *cluster.Options) {
+		opt.TraceExporter = exporter
+	}
+}
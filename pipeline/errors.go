@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrDropped is returned when a middleware of the outbound pipeline stops the
+// message being sent to the client
+var ErrDropped = errors.New("pipeline: message dropped")
+
+// Error short-circuits the inbound or forward pipeline with an error code,
+// which is responded to the client if the message is a request. Error responses
+// are flagged by message.Message.Error and encoded by Encode, not by the
+// serializer of the route, so clients of any serializer can decode them.
+type Error struct {
+	Code    int    `json:"code" msgpack:"code" cbor:"code"`
+	Message string `json:"message,omitempty" msgpack:"message,omitempty" cbor:"message,omitempty"`
+}
+
+// NewError returns an error with code and message
+func NewError(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pipeline: code %d, %s", e.Code, e.Message)
+}
+
+// Encode returns the JSON encoding of error, which is the data of error
+// responses
+func (e *Error) Encode() []byte {
+	data, _ := json.Marshal(e)
+	return data
+}
+
+// DecodeError parses the data of error responses
+func DecodeError(data []byte) (*Error, error) {
+	e := &Error{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"path"
 	"sync"
 
 	"github.com/aura-studio/nano/message"
@@ -13,24 +14,63 @@ type (
 
 	Func func(s *session.Session, msg *message.Message) error
 
+	// Handler processes a message, it's the rest of the chain for a middleware
+	Handler func(s *session.Session, msg *message.Message) error
+
+	// Middleware wraps the rest of the chain, it can run code before and after
+	// calling next, or short-circuit the chain by returning without calling next,
+	// e.g. responding to the client directly or returning an *Error
+	Middleware func(s *session.Session, msg *message.Message, next Handler) error
+
 	Pipeline interface {
 		Outbound() Channel
+		// Inbound is applied to the messages of local handlers. The chain runs
+		// in the scheduled task of the handler, so that middlewares can run code
+		// after the handler returns, funcs and middlewares must not block, which
+		// stall the scheduler of the service. Inbound funcs ran on the reading
+		// goroutine of the connection before scheduled in former versions.
 		Inbound() Channel
+		// Forward is applied to the messages forwarded to remote services by
+		// the gate, and the final handler sends them to the backends
+		Forward() Channel
+		// Backend is applied to the pushes and responses sent by the backends to
+		// the sessions on the gate, and the final handler sends them to the clients
+		Backend() Channel
 	}
 
 	pipeline struct {
 		outbound, inbound *pipelineChannel
+		forward, backend  *pipelineChannel
 	}
 
 	Channel interface {
 		PushFront(h Func)
 		PushBack(h Func)
+		// Use appends a named middleware applied to the messages whose route
+		// matches pattern, a middleware with the same name is replaced in place
+		Use(name, pattern string, m Middleware)
+		// UseFront is like Use but inserts the middleware at the front
+		UseFront(name, pattern string, m Middleware)
+		// Remove removes the named middleware, returns false if not found
+		Remove(name string) bool
+		// Names returns the names of middlewares in order, unnamed ones are
+		// registered by PushFront and PushBack
+		Names() []string
 		Process(s *session.Session, msg *message.Message) error
+		// Handle processes message with all matched middlewares, and calls final
+		// at the end of the chain if no middleware short-circuits it
+		Handle(s *session.Session, msg *message.Message, final Handler) error
 	}
 
 	pipelineChannel struct {
-		mu       sync.RWMutex
-		handlers []Func
+		mu      sync.RWMutex
+		entries []entry
+	}
+
+	entry struct {
+		name       string
+		pattern    string
+		middleware Middleware
 	}
 )
 
@@ -38,41 +78,136 @@ func New() Pipeline {
 	return &pipeline{
 		outbound: &pipelineChannel{},
 		inbound:  &pipelineChannel{},
+		forward:  &pipelineChannel{},
+		backend:  &pipelineChannel{},
 	}
 }
 
 func (p *pipeline) Outbound() Channel { return p.outbound }
 func (p *pipeline) Inbound() Channel  { return p.inbound }
+func (p *pipeline) Forward() Channel  { return p.forward }
+func (p *pipeline) Backend() Channel  { return p.backend }
+
+// Match reports whether route matches pattern, an empty pattern matches all
+// routes, otherwise the pattern syntax is the same as path.Match, such as:
+// "Room.Join", "Room.*", "*.Join"
+func Match(pattern, route string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, route)
+	return ok
+}
+
+// wrap converts a Func to a middleware, the chain stops if the func fails
+func wrap(h Func) Middleware {
+	return func(s *session.Session, msg *message.Message, next Handler) error {
+		if err := h(s, msg); err != nil {
+			return err
+		}
+		return next(s, msg)
+	}
+}
 
 // PushFront push a function to the front of the pipeline
 func (p *pipelineChannel) PushFront(h Func) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	handlers := make([]Func, len(p.handlers)+1)
-	handlers[0] = h
-	copy(handlers[1:], p.handlers)
-	p.handlers = handlers
+	p.insert(entry{middleware: wrap(h)}, true)
 }
 
 // PushFront push a function to the end of the pipeline
 func (p *pipelineChannel) PushBack(h Func) {
+	p.insert(entry{middleware: wrap(h)}, false)
+}
+
+// Use appends a named middleware to the pipeline
+func (p *pipelineChannel) Use(name, pattern string, m Middleware) {
+	p.insert(entry{name: name, pattern: pattern, middleware: m}, false)
+}
+
+// UseFront inserts a named middleware to the front of the pipeline
+func (p *pipelineChannel) UseFront(name, pattern string, m Middleware) {
+	p.insert(entry{name: name, pattern: pattern, middleware: m}, true)
+}
+
+// insert copies entries on write, so that the chains being processed are not
+// affected
+func (p *pipelineChannel) insert(e entry, front bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries := make([]entry, 0, len(p.entries)+1)
+	if front {
+		entries = append(entries, e)
+	}
+	replaced := false
+	for _, old := range p.entries {
+		if e.name != "" && old.name == e.name {
+			if !front {
+				entries = append(entries, e)
+			}
+			replaced = true
+			continue
+		}
+		entries = append(entries, old)
+	}
+	if !front && !replaced {
+		entries = append(entries, e)
+	}
+	p.entries = entries
+}
+
+// Remove removes the named middleware from the pipeline
+func (p *pipelineChannel) Remove(name string) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.handlers = append(p.handlers, h)
+
+	for i, e := range p.entries {
+		if e.name != "" && e.name == name {
+			entries := make([]entry, 0, len(p.entries)-1)
+			entries = append(entries, p.entries[:i]...)
+			p.entries = append(entries, p.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
 }
 
-// Process process message with all pipeline functions
-func (p *pipelineChannel) Process(s *session.Session, msg *message.Message) error {
+// Names returns the names of middlewares in order
+func (p *pipelineChannel) Names() []string {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	if len(p.handlers) < 1 {
-		return nil
+
+	names := make([]string, len(p.entries))
+	for i := range p.entries {
+		names[i] = p.entries[i].name
 	}
-	for _, h := range p.handlers {
-		err := h(s, msg)
-		if err != nil {
-			return err
+	return names
+}
+
+// Process process message with all pipeline functions
+func (p *pipelineChannel) Process(s *session.Session, msg *message.Message) error {
+	return p.Handle(s, msg, nil)
+}
+
+// Handle processes message with all matched middlewares and final handler
+func (p *pipelineChannel) Handle(s *session.Session, msg *message.Message, final Handler) error {
+	p.mu.RLock()
+	entries := p.entries
+	p.mu.RUnlock()
+
+	var next func(i int) Handler
+	next = func(i int) Handler {
+		return func(s *session.Session, msg *message.Message) error {
+			for ; i < len(entries); i++ {
+				if Match(entries[i].pattern, msg.Route) {
+					return entries[i].middleware(s, msg, next(i+1))
+				}
+			}
+			if final == nil {
+				return nil
+			}
+			return final(s, msg)
 		}
 	}
-	return nil
+	return next(0)(s, msg)
 }
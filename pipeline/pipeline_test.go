@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/session"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, route string
+		match          bool
+	}{
+		{"", "Room.Join", true},
+		{"*", "Room.Join", true},
+		{"Room.*", "Room.Join", true},
+		{"Room.*", "Chat.Join", false},
+		{"*.Join", "Chat.Join", true},
+		{"Room.Join", "Room.Join", true},
+		{"Room.Join", "Room.Leave", false},
+	}
+	for _, c := range cases {
+		if Match(c.pattern, c.route) != c.match {
+			t.Fatalf("pattern: %s, route: %s, expect: %v", c.pattern, c.route, c.match)
+		}
+	}
+}
+
+func TestChannel(t *testing.T) {
+	var trace []string
+	around := func(name string) Middleware {
+		return func(s *session.Session, msg *message.Message, next Handler) error {
+			trace = append(trace, name+">")
+			err := next(s, msg)
+			trace = append(trace, "<"+name)
+			return err
+		}
+	}
+	final := func(s *session.Session, msg *message.Message) error {
+		trace = append(trace, "handler")
+		return nil
+	}
+
+	c := New().Inbound()
+	c.Use("room", "Room.*", around("room"))
+	c.Use("all", "", around("all"))
+	c.PushBack(func(s *session.Session, msg *message.Message) error {
+		trace = append(trace, "func")
+		return nil
+	})
+	c.UseFront("first", "*.Join", around("first"))
+
+	if names := c.Names(); !reflect.DeepEqual(names, []string{"first", "room", "all", ""}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	if err := c.Handle(nil, &message.Message{Route: "Room.Join"}, final); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"first>", "room>", "all>", "func", "handler", "<all", "<room", "<first"}
+	if !reflect.DeepEqual(trace, expect) {
+		t.Fatalf("unexpected trace: %v", trace)
+	}
+
+	trace = nil
+	if err := c.Handle(nil, &message.Message{Route: "Chat.Say"}, final); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"all>", "func", "handler", "<all"}; !reflect.DeepEqual(trace, expect) {
+		t.Fatalf("unexpected trace: %v", trace)
+	}
+
+	// replace in place and remove
+	c.Use("room", "Room.*", func(s *session.Session, msg *message.Message, next Handler) error {
+		return NewError(403, "forbidden")
+	})
+	if !c.Remove("first") || c.Remove("first") {
+		t.Fatal("unexpected remove result")
+	}
+	if names := c.Names(); !reflect.DeepEqual(names, []string{"room", "all", ""}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	trace = nil
+	err := c.Handle(nil, &message.Message{Route: "Room.Join"}, final)
+	if e, ok := err.(*Error); !ok || e.Code != 403 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(trace) != 0 {
+		t.Fatalf("unexpected trace: %v", trace)
+	}
+}
+
+func TestChannelFuncError(t *testing.T) {
+	errFunc := errors.New("func error")
+	called := false
+	c := New().Outbound()
+	c.PushFront(func(s *session.Session, msg *message.Message) error {
+		return errFunc
+	})
+	err := c.Handle(nil, &message.Message{Route: "onPush"}, func(s *session.Session, msg *message.Message) error {
+		called = true
+		return nil
+	})
+	if err != errFunc || called {
+		t.Fatalf("unexpected result: %v, %v", err, called)
+	}
+	if err := c.Process(nil, &message.Message{}); err != errFunc {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
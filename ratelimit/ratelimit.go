@@ -0,0 +1,213 @@
+// Package ratelimit limits messages from clients by token buckets, which are
+// keyed by session, UID, route or IP and enforced on the gate before messages
+// are dispatched.
+package ratelimit
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/session"
+)
+
+// Key is the dimension which buckets of a rule are keyed by
+type Key int
+
+// Keys of buckets
+const (
+	BySession Key = iota + 1
+	ByUID
+	ByRoute
+	ByIP
+)
+
+func (k Key) String() string {
+	switch k {
+	case BySession:
+		return "session"
+	case ByUID:
+		return "uid"
+	case ByRoute:
+		return "route"
+	case ByIP:
+		return "ip"
+	}
+	return "unknown"
+}
+
+// Action is the action taken on limited messages
+type Action int
+
+// Actions on limited messages
+const (
+	// Drop discards limited messages silently
+	Drop Action = iota
+	// Respond responds limited requests with the error code of rule, limited
+	// notifies are dropped
+	Respond
+	// Disconnect closes the session
+	Disconnect
+)
+
+func (a Action) String() string {
+	switch a {
+	case Drop:
+		return "drop"
+	case Respond:
+		return "respond"
+	case Disconnect:
+		return "disconnect"
+	}
+	return "unknown"
+}
+
+// ErrInvalidRule represents a rule without key or a non-positive rate or burst
+var ErrInvalidRule = errors.New("ratelimit: invalid rule")
+
+// sweepInterval is the interval of removing idle buckets
+var sweepInterval = time.Minute
+
+// Rule limits messages of routes matched by Pattern to Rate per second with
+// bursts of Burst, buckets are keyed by Key. UID rules skip sessions not bound
+// to a UID.
+type Rule struct {
+	Key     Key
+	Pattern string // route pattern, the syntax is the same as pipeline.Match
+	Rate    float64
+	Burst   int
+	Action  Action
+	Code    int // error code responded by Respond
+}
+
+// Error returns the error responded to limited requests
+func (r *Rule) Error() *pipeline.Error {
+	return pipeline.NewError(r.Code, "rate limited")
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and takes a token if there is one
+func (b *bucket) take(now time.Time, rate float64, burst int) bool {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// full reports whether the bucket will be full at now, which is the same as
+// a new bucket
+func (b *bucket) full(now time.Time, rate float64, burst int) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst)
+}
+
+// Limiter checks messages against rules in order
+type Limiter struct {
+	rules []Rule
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   []map[string]*bucket // buckets of each rule
+	lastSweep time.Time
+}
+
+// New returns a limiter with rules, ErrInvalidRule is returned if a rule is
+// invalid
+func New(rules ...Rule) (*Limiter, error) {
+	l := &Limiter{
+		rules:   append([]Rule(nil), rules...),
+		now:     time.Now,
+		buckets: make([]map[string]*bucket, len(rules)),
+	}
+	for i := range l.rules {
+		r := &l.rules[i]
+		if r.Key < BySession || r.Key > ByIP || r.Rate <= 0 || r.Burst <= 0 {
+			return nil, ErrInvalidRule
+		}
+		l.buckets[i] = make(map[string]*bucket)
+	}
+	l.lastSweep = l.now()
+	return l, nil
+}
+
+func bucketKey(key Key, s *session.Session, route string) (string, bool) {
+	switch key {
+	case BySession:
+		return strconv.FormatInt(s.ID(), 10), true
+	case ByUID:
+		uid := s.UID()
+		return strconv.FormatInt(uid, 10), uid != 0
+	case ByRoute:
+		return route, true
+	case ByIP:
+		addr := s.RemoteAddr()
+		if addr == nil {
+			return "", false
+		}
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			host = addr.String()
+		}
+		return host, true
+	}
+	return "", false
+}
+
+// Limit takes tokens of the message from buckets of matched rules, returns the
+// first rule limiting the message, or nil if the message is allowed. Routes
+// from clients should be known routes or a placeholder of unknown routes, since
+// ByRoute buckets are keyed by routes.
+func (l *Limiter) Limit(s *session.Session, route string) *Rule {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	for i := range l.rules {
+		r := &l.rules[i]
+		if !pipeline.Match(r.Pattern, route) {
+			continue
+		}
+		key, ok := bucketKey(r.Key, s, route)
+		if !ok {
+			continue
+		}
+		b, found := l.buckets[i][key]
+		if !found {
+			b = &bucket{tokens: float64(r.Burst), last: now}
+			l.buckets[i][key] = b
+		}
+		if !b.take(now, r.Rate, r.Burst) {
+			return r
+		}
+	}
+	return nil
+}
+
+// sweep removes idle buckets, it should be called with the lock held
+func (l *Limiter) sweep(now time.Time) {
+	for i, buckets := range l.buckets {
+		r := &l.rules[i]
+		for key, b := range buckets {
+			if b.full(now, r.Rate, r.Burst) {
+				delete(buckets, key)
+			}
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano/mock"
+	"github.com/aura-studio/nano/session"
+)
+
+type addrEntity struct {
+	*mock.NetworkEntity
+	addr string
+}
+
+func (e *addrEntity) RemoteAddr() net.Addr {
+	addr, _ := net.ResolveTCPAddr("tcp", e.addr)
+	return addr
+}
+
+func newSession(id, uid int64, addr string) *session.Session {
+	s := session.New(&addrEntity{NetworkEntity: mock.NewNetworkEntity(), addr: addr}, id)
+	s.BindUID(uid)
+	return s
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l, err := New(
+		Rule{Key: BySession, Pattern: "Room.Chat", Rate: 1, Burst: 2, Action: Respond, Code: 429},
+		Rule{Key: ByUID, Rate: 10, Burst: 3, Action: Disconnect},
+		Rule{Key: ByIP, Pattern: "Login.*", Rate: 1, Burst: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+
+	a := newSession(1, 0, "10.0.0.1:1000")
+	b := newSession(2, 0, "10.0.0.1:1001")
+
+	for i := 0; i < 2; i++ {
+		if r := l.Limit(a, "Room.Chat"); r != nil {
+			t.Fatalf("message %d is limited by %v", i, r.Key)
+		}
+	}
+	if r := l.Limit(a, "Room.Chat"); r == nil || r.Key != BySession || r.Error().Code != 429 {
+		t.Fatalf("unexpected rule: %+v", r)
+	}
+	if r := l.Limit(b, "Room.Chat"); r != nil {
+		t.Fatalf("other session is limited by %v", r.Key)
+	}
+
+	// tokens are refilled by rate
+	now = now.Add(time.Second)
+	if r := l.Limit(a, "Room.Chat"); r != nil {
+		t.Fatalf("refilled session is limited by %v", r.Key)
+	}
+
+	// sessions from the same ip share buckets of ip rules
+	if r := l.Limit(a, "Login.Auth"); r != nil {
+		t.Fatalf("login is limited by %v", r.Key)
+	}
+	if r := l.Limit(b, "Login.Auth"); r == nil || r.Key != ByIP || r.Action != Drop {
+		t.Fatalf("unexpected rule: %+v", r)
+	}
+
+	// sessions of the same uid share buckets of uid rules
+	c := newSession(3, 1001, "10.0.0.2:1000")
+	d := newSession(4, 1001, "10.0.0.3:1000")
+	for i := 0; i < 3; i++ {
+		if r := l.Limit([]*session.Session{c, d}[i%2], "Room.Move"); r != nil {
+			t.Fatalf("message %d is limited by %v", i, r.Key)
+		}
+	}
+	if r := l.Limit(d, "Room.Move"); r == nil || r.Key != ByUID || r.Action != Disconnect {
+		t.Fatalf("unexpected rule: %+v", r)
+	}
+
+	// idle buckets are swept
+	now = now.Add(sweepInterval)
+	l.Limit(a, "Room.Move")
+	for i, buckets := range l.buckets {
+		if len(buckets) != 0 {
+			t.Fatalf("buckets of rule %d are not swept: %v", i, buckets)
+		}
+	}
+}
+
+func TestInvalidRule(t *testing.T) {
+	if _, err := New(Rule{Key: BySession, Rate: 1}); err != ErrInvalidRule {
+		t.Fatalf("invalid rule is accepted: %v", err)
+	}
+}
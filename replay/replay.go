@@ -0,0 +1,172 @@
+// Package replay protects gates from replayed messages. Request IDs of each
+// session must increase monotonically, or be unique within a window, and
+// payloads can be signed by HMAC-SHA256 with a per-session secret, which is
+// appended to the data of messages. Signed notifies are numbered by message IDs
+// from the same sequence as requests, unsigned notifies are not protected.
+package replay
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/pipeline"
+	"github.com/aura-studio/nano/session"
+)
+
+// DefaultCode is the default error code responded to rejected requests
+const DefaultCode = 409
+
+// SignatureSize is the size of signature appended to data
+const SignatureSize = sha256.Size
+
+// secretKey is the key of session data storing the secret
+const secretKey = "nano.replay.secret"
+
+// Errors of rejected messages
+var (
+	ErrReplayed         = errors.New("replay: request id replayed")
+	ErrInvalidSignature = errors.New("replay: invalid signature")
+)
+
+// Config is the config of replay protection on the gate
+type Config struct {
+	// Window is the count of recent request IDs which can arrive out of order,
+	// request IDs must increase monotonically if zero, which requires clients
+	// to send messages in the order of IDs, such as connector.Connector
+	Window int
+
+	// Secret returns the secret of session to verify signatures, messages of
+	// sessions without a secret are not verified. The secret set by SetSecret
+	// is used if nil.
+	Secret func(s *session.Session) []byte
+
+	// Code is the error code responded to rejected requests, DefaultCode is
+	// used if zero
+	Code int
+}
+
+// SecretOf returns the secret of session
+func (c *Config) SecretOf(s *session.Session) []byte {
+	if c.Secret != nil {
+		return c.Secret(s)
+	}
+	secret, _ := s.Value(secretKey).([]byte)
+	return secret
+}
+
+// Error returns the error responded to rejected requests
+func (c *Config) Error() *pipeline.Error {
+	code := c.Code
+	if code == 0 {
+		code = DefaultCode
+	}
+	return pipeline.NewError(code, "replayed")
+}
+
+// SetSecret sets the secret of session, such as in auth.Config.Login, messages
+// of the session are verified after that
+func SetSecret(s *session.Session, secret []byte) {
+	s.Set(secretKey, secret)
+}
+
+func mac(secret []byte, typ message.Type, id uint64, route string, data []byte) []byte {
+	var header [9]byte
+	header[0] = byte(typ)
+	binary.BigEndian.PutUint64(header[1:], id)
+
+	h := hmac.New(sha256.New, secret)
+	h.Write(header[:])
+	h.Write([]byte(route))
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Sign returns the data appended with the signature of message, the id is
+// covered by the signature, so that it cannot be changed by replayed messages
+func Sign(secret []byte, typ message.Type, id uint64, route string, data []byte) []byte {
+	signed := make([]byte, 0, len(data)+SignatureSize)
+	signed = append(signed, data...)
+	return append(signed, mac(secret, typ, id, route, data)...)
+}
+
+// Verify verifies the signature appended to data, and returns the data
+// without signature
+func Verify(secret []byte, typ message.Type, id uint64, route string, data []byte) ([]byte, error) {
+	if len(data) < SignatureSize {
+		return nil, ErrInvalidSignature
+	}
+	payload, signature := data[:len(data)-SignatureSize], data[len(data)-SignatureSize:]
+	if !hmac.Equal(signature, mac(secret, typ, id, route, payload)) {
+		return nil, ErrInvalidSignature
+	}
+	return payload, nil
+}
+
+// Window validates request IDs of a session, it's not safe for concurrent use
+type Window struct {
+	size    uint64
+	highest uint64
+	seen    []uint64 // bitmap of recent ids, indexed by id % size
+}
+
+// NewWindow returns a window of size, request IDs must increase monotonically
+// if size is zero
+func NewWindow(size int) *Window {
+	w := &Window{}
+	if size > 0 {
+		w.size = uint64(size)
+		w.seen = make([]uint64, (size+63)/64)
+	}
+	return w
+}
+
+func (w *Window) bit(id uint64) (int, uint64) {
+	i := id % w.size
+	return int(i / 64), 1 << (i % 64)
+}
+
+// Check accepts the request ID if it is not replayed
+func (w *Window) Check(id uint64) error {
+	if id == 0 {
+		return ErrReplayed
+	}
+	if w.size == 0 {
+		if id <= w.highest {
+			return ErrReplayed
+		}
+		w.highest = id
+		return nil
+	}
+
+	if id > w.highest {
+		// forget ids slid out of the window
+		if id-w.highest >= w.size {
+			for i := range w.seen {
+				w.seen[i] = 0
+			}
+		} else {
+			for i := w.highest + 1; i < id; i++ {
+				n, mask := w.bit(i)
+				w.seen[n] &^= mask
+			}
+		}
+		w.highest = id
+		n, mask := w.bit(id)
+		w.seen[n] |= mask
+		return nil
+	}
+
+	if w.highest-id >= w.size {
+		return ErrReplayed
+	}
+	n, mask := w.bit(id)
+	if w.seen[n]&mask != 0 {
+		return ErrReplayed
+	}
+	w.seen[n] |= mask
+	return nil
+}
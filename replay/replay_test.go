@@ -0,0 +1,99 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/mock"
+	"github.com/aura-studio/nano/session"
+)
+
+func TestWindow(t *testing.T) {
+	w := NewWindow(0)
+	for _, id := range []uint64{1, 2, 5} {
+		if err := w.Check(id); err != nil {
+			t.Fatalf("id %d rejected: %v", id, err)
+		}
+	}
+	for _, id := range []uint64{0, 4, 5} {
+		if err := w.Check(id); err != ErrReplayed {
+			t.Fatalf("id %d accepted by strict window", id)
+		}
+	}
+
+	w = NewWindow(4)
+	for _, id := range []uint64{3, 1, 2, 6, 4} {
+		if err := w.Check(id); err != nil {
+			t.Fatalf("id %d rejected: %v", id, err)
+		}
+	}
+	// 2 is out of the window, others are received before
+	for _, id := range []uint64{2, 3, 4, 6} {
+		if err := w.Check(id); err != ErrReplayed {
+			t.Fatalf("id %d accepted twice", id)
+		}
+	}
+	if err := w.Check(5); err != nil {
+		t.Fatalf("id 5 rejected: %v", err)
+	}
+
+	// slots are reused after the window slides
+	if err := w.Check(100); err != nil {
+		t.Fatalf("id 100 rejected: %v", err)
+	}
+	for _, id := range []uint64{98, 99} {
+		if err := w.Check(id); err != nil {
+			t.Fatalf("id %d rejected: %v", id, err)
+		}
+	}
+	if err := w.Check(96); err != ErrReplayed {
+		t.Fatal("id 96 accepted out of the window")
+	}
+}
+
+func TestSignature(t *testing.T) {
+	secret := []byte("secret")
+	data := []byte("payload")
+	signed := Sign(secret, message.Request, 7, "Room.Join", data)
+	if len(signed) != len(data)+SignatureSize {
+		t.Fatalf("unexpected signed size: %d", len(signed))
+	}
+
+	payload, err := Verify(secret, message.Request, 7, "Room.Join", signed)
+	if err != nil || string(payload) != "payload" {
+		t.Fatalf("unexpected verify result: %q, %v", payload, err)
+	}
+
+	for _, tc := range []struct {
+		secret string
+		typ    message.Type
+		id     uint64
+		route  string
+		data   []byte
+	}{
+		{"forged", message.Request, 7, "Room.Join", signed},
+		{"secret", message.Notify, 7, "Room.Join", signed},
+		{"secret", message.Request, 8, "Room.Join", signed},
+		{"secret", message.Request, 7, "Room.Leave", signed},
+		{"secret", message.Request, 7, "Room.Join", data},
+	} {
+		if _, err := Verify([]byte(tc.secret), tc.typ, tc.id, tc.route, tc.data); err != ErrInvalidSignature {
+			t.Fatalf("unexpected verify result of %+v: %v", tc, err)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	s := session.New(mock.NewNetworkEntity(), 1)
+	c := &Config{}
+	if c.SecretOf(s) != nil {
+		t.Fatal("unexpected secret before set")
+	}
+	SetSecret(s, []byte("secret"))
+	if string(c.SecretOf(s)) != "secret" {
+		t.Fatalf("unexpected secret: %q", c.SecretOf(s))
+	}
+	if c.Error().Code != DefaultCode {
+		t.Fatalf("unexpected error code: %d", c.Error().Code)
+	}
+}
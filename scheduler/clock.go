@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aura-studio/nano/env"
+)
+
+type (
+	// Clock provides the time of scheduler, timers are checked on the ticks of
+	// clock, which can be replaced by a fake clock in tests
+	Clock interface {
+		Now() time.Time
+		NewTicker(d time.Duration) Ticker
+	}
+
+	// Ticker delivers ticks of a clock
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+	}
+
+	realClock  struct{}
+	realTicker struct{ *time.Ticker }
+)
+
+func (realClock) Now() time.Time                   { return time.Now() }
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+func (t realTicker) C() <-chan time.Time           { return t.Ticker.C }
+
+var (
+	muClock sync.RWMutex
+	clock   Clock = realClock{}
+
+	// ticker is only accessed in the Digest goroutine
+	ticker Ticker
+)
+
+// SetClock replaces the clock of scheduler, the real clock is restored if c is
+// nil. It waits the running Digest to switch ticker, so it must not be called in
+// scheduled tasks.
+func SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	muClock.Lock()
+	clock = c
+	muClock.Unlock()
+
+	if atomic.LoadInt32(&started) == 0 || atomic.LoadInt32(&closed) != 0 {
+		return
+	}
+	done := make(chan struct{})
+	PushTask(func() {
+		resetTicker()
+		close(done)
+	})
+	select {
+	case <-done:
+	case <-chExit:
+	}
+}
+
+// Now returns the current time of scheduler clock
+func Now() time.Time {
+	muClock.RLock()
+	defer muClock.RUnlock()
+	return clock.Now()
+}
+
+func resetTicker() {
+	if ticker != nil {
+		ticker.Stop()
+	}
+	muClock.RLock()
+	ticker = clock.NewTicker(env.TimerPrecision)
+	muClock.RUnlock()
+}
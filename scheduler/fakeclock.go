@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FakeClock is a Clock for tests and replay tools, time only moves by Set and
+// Advance, which run due timers deterministically before return
+//
+//	clock := scheduler.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+//	scheduler.SetClock(clock)
+//	defer scheduler.SetClock(nil)
+//	clock.Advance(24 * time.Hour)
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a fake clock starts at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker never ticks, timers are run by Advance
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	return fakeTicker{}
+}
+
+// Set moves the clock to t and runs due timers
+func (c *FakeClock) Set(t time.Time) {
+	c.Advance(t.Sub(c.Now()))
+}
+
+// Advance moves the clock forward and runs due timers in the order of their
+// deadlines, a periodic timer runs once for each elapsed interval, condition
+// timers are checked once at each deadline and at the end. Advance(0) checks timers
+// at current time, such as condition timers after state changed.
+//
+// Timers run in the Digest goroutine if it's running, otherwise in the caller
+// goroutine, so it must not be called in scheduled tasks.
+func (c *FakeClock) Advance(d time.Duration) {
+	target := c.Now().Add(d).UnixNano()
+	ran := false
+	for {
+		var next int64
+		runTimers(func() { next = nextDeadline(target) })
+		if next == 0 {
+			// timers have been checked at target
+			if ran && c.Now().UnixNano() == target {
+				return
+			}
+			c.set(target)
+			runTimers(cron)
+			return
+		}
+		// overdue timers run at current time
+		if now := c.Now().UnixNano(); next < now {
+			next = now
+		}
+		c.set(next)
+		runTimers(cron)
+		ran = true
+	}
+}
+
+func (c *FakeClock) set(unn int64) {
+	c.mu.Lock()
+	c.now = time.Unix(0, unn).In(c.now.Location())
+	c.mu.Unlock()
+}
+
+type fakeTicker struct{}
+
+func (fakeTicker) C() <-chan time.Time { return nil }
+func (fakeTicker) Stop()               {}
+
+// muRunTimers serializes timers run out of the Digest goroutine
+var muRunTimers sync.Mutex
+
+// runTimers runs fn in the Digest goroutine if it's running and waits for it,
+// otherwise runs fn in the caller goroutine
+func runTimers(fn func()) {
+	if atomic.LoadInt32(&started) == 0 || atomic.LoadInt32(&closed) != 0 {
+		muRunTimers.Lock()
+		defer muRunTimers.Unlock()
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	PushTask(func() {
+		fn()
+		close(done)
+	})
+	select {
+	case <-done:
+	case <-chExit:
+	}
+}
+
+// nextDeadline returns the earliest deadline not after target of active timers,
+// zero if no timer is due
+func nextDeadline(target int64) int64 {
+	var next int64
+	check := func(t *Timer) {
+		if t.condition != nil || atomic.LoadInt32(&t.closed) != 0 {
+			return
+		}
+		if t.counter != infinite && t.counter <= 0 {
+			return
+		}
+		deadline := t.createAt + t.elapse
+		if deadline <= target && (next == 0 || deadline < next) {
+			next = deadline
+		}
+	}
+
+	timerManager.muCreatedTimer.RLock()
+	for _, t := range timerManager.createdTimer {
+		check(t)
+	}
+	timerManager.muCreatedTimer.RUnlock()
+
+	for _, t := range timerManager.timers {
+		check(t)
+	}
+	return next
+}
@@ -23,9 +23,7 @@ package scheduler
 import (
 	"runtime/debug"
 	"sync/atomic"
-	"time"
 
-	"github.com/aura-studio/nano/env"
 	"github.com/aura-studio/nano/log"
 	"github.com/aura-studio/nano/session"
 )
@@ -38,7 +36,8 @@ const (
 // Task is the unit to be scheduled
 type Task func()
 
-// SchedFunc is the Func type of schedule
+// SchedFunc is the Func type of schedule, v is the deserialized payload of
+// the message to be handled
 type SchedFunc func(session *session.Session, v interface{}, task Task)
 
 var (
@@ -64,7 +63,7 @@ func Digest() {
 		return
 	}
 
-	ticker := time.NewTicker(env.TimerPrecision)
+	resetTicker()
 	defer func() {
 		ticker.Stop()
 		close(chExit)
@@ -72,7 +71,7 @@ func Digest() {
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			cron()
 
 		case f := <-chTasks:
@@ -102,3 +101,8 @@ func Schedule(_ *session.Session, _ interface{}, task Task) {
 func PushTask(task Task) {
 	chTasks <- task
 }
+
+// QueueDepth returns the count of tasks waiting in task channel
+func QueueDepth() int {
+	return len(chTasks)
+}
@@ -23,6 +23,7 @@ package scheduler
 import (
 	"math"
 	"runtime/debug"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -38,6 +39,7 @@ var (
 	// timerManager manager for all timers
 	timerManager = &struct {
 		incrementID int64            // auto increment id
+		count       int64            // count of active timers
 		timers      map[int64]*Timer // all timers
 
 		muClosingTimer sync.RWMutex
@@ -114,7 +116,7 @@ func cron() {
 		return
 	}
 
-	now := time.Now()
+	now := Now()
 	unn := now.UnixNano()
 	for id, t := range timerManager.timers {
 		if t.counter == infinite || t.counter > 0 {
@@ -151,6 +153,7 @@ func cron() {
 		for _, id := range timerManager.closingTimer {
 			delete(timerManager.timers, id)
 		}
+		atomic.AddInt64(&timerManager.count, -int64(len(timerManager.closingTimer)))
 		timerManager.closingTimer = timerManager.closingTimer[:0]
 		timerManager.muClosingTimer.Unlock()
 	}
@@ -181,7 +184,7 @@ func NewCountTimer(interval time.Duration, count int, fn TimerFunc) *Timer {
 	t := &Timer{
 		id:       atomic.AddInt64(&timerManager.incrementID, 1),
 		fn:       fn,
-		createAt: time.Now().UnixNano(),
+		createAt: Now().UnixNano(),
 		interval: interval,
 		elapse:   int64(interval), // first execution will be after interval
 		counter:  count,
@@ -190,9 +193,15 @@ func NewCountTimer(interval time.Duration, count int, fn TimerFunc) *Timer {
 	timerManager.muCreatedTimer.Lock()
 	timerManager.createdTimer = append(timerManager.createdTimer, t)
 	timerManager.muCreatedTimer.Unlock()
+	atomic.AddInt64(&timerManager.count, 1)
 	return t
 }
 
+// TimerCount returns the count of timers not released yet
+func TimerCount() int64 {
+	return atomic.LoadInt64(&timerManager.count)
+}
+
 // NewAfterTimer returns a new Timer containing a function that will be called
 // after duration that specified by the duration argument.
 // The duration d must be greater than zero; if not, NewAfterTimer will panic.
@@ -215,3 +224,47 @@ func NewCondTimer(condition TimerCondition, fn TimerFunc) *Timer {
 
 	return t
 }
+
+// TimerInfo describes a timer
+type TimerInfo struct {
+	ID        int64         `json:"id"`
+	Interval  time.Duration `json:"interval"`
+	Counter   int           `json:"counter"` // remaining executions, -1 means infinite
+	Condition bool          `json:"condition"`
+	Next      time.Time     `json:"next,omitempty"` // zero for condition timers
+}
+
+// Timers returns the info of timers not released yet, which is read in the
+// Digest goroutine if it's running
+func Timers() []TimerInfo {
+	var infos []TimerInfo
+	runTimers(func() {
+		add := func(t *Timer) {
+			info := TimerInfo{
+				ID:        t.id,
+				Interval:  t.interval,
+				Counter:   t.counter,
+				Condition: t.condition != nil,
+			}
+			if t.condition == nil {
+				info.Next = time.Unix(0, t.createAt+t.elapse)
+			} else {
+				info.Interval = 0
+			}
+			infos = append(infos, info)
+		}
+
+		timerManager.muCreatedTimer.RLock()
+		for _, t := range timerManager.createdTimer {
+			add(t)
+		}
+		timerManager.muCreatedTimer.RUnlock()
+		for _, t := range timerManager.timers {
+			if t.counter != 0 {
+				add(t)
+			}
+		}
+	})
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
@@ -102,3 +102,40 @@ func TestNewAfterTimer(t *testing.T) {
 		t.Fatalf("closingTimer: %d", len(timerManager.closingTimer))
 	}
 }
+
+func TestFakeClock(t *testing.T) {
+	// earlier than timers created by other tests
+	clock := NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	SetClock(clock)
+	defer SetClock(nil)
+
+	var hourly, daily, cond int64
+	NewTimer(time.Hour, func() { atomic.AddInt64(&hourly, 1) })
+	NewAfterTimer(24*time.Hour, func() { atomic.AddInt64(&daily, 1) })
+	reset := clock.Now().Add(36 * time.Hour)
+	NewCondTimer(condition(func(now time.Time) bool { return !now.Before(reset) }), func() {
+		atomic.AddInt64(&cond, 1)
+	})
+
+	clock.Advance(24*time.Hour - time.Nanosecond)
+	if hourly != 23 || daily != 0 || cond != 0 {
+		t.Fatalf("hourly: %d, daily: %d, cond: %d", hourly, daily, cond)
+	}
+
+	clock.Advance(time.Nanosecond)
+	if hourly != 24 || daily != 1 || cond != 0 {
+		t.Fatalf("hourly: %d, daily: %d, cond: %d", hourly, daily, cond)
+	}
+
+	clock.Set(reset)
+	if hourly != 36 || daily != 1 || cond != 1 {
+		t.Fatalf("hourly: %d, daily: %d, cond: %d", hourly, daily, cond)
+	}
+	if !clock.Now().Equal(reset) {
+		t.Fatalf("unexpected time: %v", clock.Now())
+	}
+}
+
+type condition func(now time.Time) bool
+
+func (c condition) Check(now time.Time) bool { return c(now) }
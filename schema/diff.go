@@ -0,0 +1,193 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Change represents a difference between two schemas
+type Change struct {
+	Route    string `json:"route"`
+	Breaking bool   `json:"breaking"`
+	Message  string `json:"message"`
+}
+
+// String, implementation of fmt.Stringer interface
+func (c Change) String() string {
+	if c.Breaking {
+		return fmt.Sprintf("[BREAKING] %s: %s", c.Route, c.Message)
+	}
+	return fmt.Sprintf("%s: %s", c.Route, c.Message)
+}
+
+// Diff returns the changes from old schema to new schema, a change is breaking
+// when clients built with old schema can not work with new servers
+func Diff(old, new *Schema) []Change {
+	d := &differ{old: old, new: new}
+
+	oldHandlers, newHandlers := handlers(old), handlers(new)
+	for route, oh := range oldHandlers {
+		nh, ok := newHandlers[route]
+		if !ok {
+			d.add(route, true, "handler removed")
+			continue
+		}
+		if oh.Code != nh.Code {
+			d.add(route, true, fmt.Sprintf("route code changed from %d to %d", oh.Code, nh.Code))
+		}
+		if oh.Serializer.Type != nh.Serializer.Type {
+			d.add(route, true, fmt.Sprintf("serializer changed from %s to %s", oh.Serializer.Name, nh.Serializer.Name))
+		}
+		d.compareType(route, "request", oh.Request, nh.Request, true)
+		d.compareType(route, "response", oh.Response, nh.Response, false)
+	}
+	for route := range newHandlers {
+		if _, ok := oldHandlers[route]; !ok {
+			d.add(route, false, "handler added")
+		}
+	}
+
+	oldPushes, newPushes := pushes(old), pushes(new)
+	for route, op := range oldPushes {
+		np, ok := newPushes[route]
+		if !ok {
+			d.add(route, true, "push removed")
+			continue
+		}
+		if op.Serializer.Type != np.Serializer.Type {
+			d.add(route, true, fmt.Sprintf("serializer changed from %s to %s", op.Serializer.Name, np.Serializer.Name))
+		}
+		d.compareType(route, "push", op.Payload, np.Payload, false)
+	}
+	for route := range newPushes {
+		if _, ok := oldPushes[route]; !ok {
+			d.add(route, false, "push added")
+		}
+	}
+
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		if d.changes[i].Route != d.changes[j].Route {
+			return d.changes[i].Route < d.changes[j].Route
+		}
+		return d.changes[i].Message < d.changes[j].Message
+	})
+	return d.changes
+}
+
+// Breaking reports whether any of changes is breaking
+func Breaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+type differ struct {
+	old, new *Schema
+	changes  []Change
+}
+
+func (d *differ) add(route string, breaking bool, msg string) {
+	d.changes = append(d.changes, Change{Route: route, Breaking: breaking, Message: msg})
+}
+
+func (d *differ) compareType(route, name string, old, new *Type, request bool) {
+	if old == nil || new == nil || old.Kind == Unknown || new.Kind == Unknown {
+		return
+	}
+	if old.Kind != new.Kind {
+		d.add(route, true, fmt.Sprintf("%s kind changed from %s to %s", name, old.Kind, new.Kind))
+		return
+	}
+	if old.Proto != nil && new.Proto != nil && old.Proto.Message != new.Proto.Message {
+		d.add(route, true, fmt.Sprintf("%s message changed from %s to %s", name, old.Proto.Message, new.Proto.Message))
+	}
+	d.compareSchema(route, name, old.Schema, new.Schema, request, map[[2]*JSONSchema]bool{})
+}
+
+func (d *differ) compareSchema(route, path string, old, new *JSONSchema, request bool, visited map[[2]*JSONSchema]bool) {
+	if old == nil || new == nil {
+		return
+	}
+	old, new = d.resolve(d.old, old), d.resolve(d.new, new)
+	if old.Type != new.Type || old.Format != new.Format {
+		d.add(route, true, fmt.Sprintf("%s type changed from %s to %s", path, typeName(old), typeName(new)))
+		return
+	}
+
+	// compare each pair once, avoid infinite loop on recursive types
+	key := [2]*JSONSchema{old, new}
+	if visited[key] {
+		return
+	}
+	visited[key] = true
+
+	d.compareSchema(route, path+"[]", old.Items, new.Items, request, visited)
+	d.compareSchema(route, path+"{}", old.AdditionalProperties, new.AdditionalProperties, request, visited)
+
+	for prop, op := range old.Properties {
+		np, ok := new.Properties[prop]
+		if !ok {
+			// fields sent by old clients are ignored by new servers
+			d.add(route, !request, fmt.Sprintf("%s.%s removed", path, prop))
+			continue
+		}
+		d.compareSchema(route, path+"."+prop, op, np, request, visited)
+	}
+	for prop := range new.Properties {
+		if _, ok := old.Properties[prop]; ok {
+			continue
+		}
+		newRequired := false
+		for _, r := range new.Required {
+			if r == prop {
+				newRequired = true
+			}
+		}
+		// old clients can not send new required fields
+		d.add(route, request && newRequired, fmt.Sprintf("%s.%s added", path, prop))
+	}
+}
+
+func (d *differ) resolve(s *Schema, js *JSONSchema) *JSONSchema {
+	for js.Ref != "" {
+		def, ok := s.Definitions[DefinitionName(js.Ref)]
+		if !ok {
+			return js
+		}
+		js = def
+	}
+	return js
+}
+
+func typeName(js *JSONSchema) string {
+	if js.Format != "" {
+		return js.Type + "(" + js.Format + ")"
+	}
+	if js.Type == "" {
+		return "any"
+	}
+	return js.Type
+}
+
+func handlers(s *Schema) map[string]*Handler {
+	result := make(map[string]*Handler)
+	for _, service := range s.Services {
+		for _, h := range service.Handlers {
+			result[h.Route] = h
+		}
+	}
+	return result
+}
+
+func pushes(s *Schema) map[string]*Push {
+	result := make(map[string]*Push)
+	for _, service := range s.Services {
+		for _, p := range service.Pushes {
+			result[p.Route] = p
+		}
+	}
+	return result
+}
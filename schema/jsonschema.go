@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSONSchema is a subset of JSON schema which describes a payload type, named
+// struct types are described in schema definitions and referred by `$ref`
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+}
+
+const definitionsPrefix = "#/definitions/"
+
+var typeOfTime = reflect.TypeOf(time.Time{})
+
+type generator struct {
+	definitions map[string]*JSONSchema
+}
+
+func newGenerator() *generator {
+	return &generator{definitions: make(map[string]*JSONSchema)}
+}
+
+// DefinitionName returns the definition name referred by a `$ref`
+func DefinitionName(ref string) string {
+	return strings.TrimPrefix(ref, definitionsPrefix)
+}
+
+// schemaOf returns the JSON schema of type, fields are named by `json` tag
+func (g *generator) schemaOf(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer", Format: t.Kind().String()}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number", Format: t.Kind().String()}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", Format: "byte"}
+		}
+		return &JSONSchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t == typeOfTime {
+			return &JSONSchema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.String()
+		if _, ok := g.definitions[name]; !ok {
+			// placeholder for recursive types
+			g.definitions[name] = &JSONSchema{}
+			*g.definitions[name] = *g.structSchema(t)
+		}
+		return &JSONSchema{Ref: definitionsPrefix + name}
+	default:
+		// interface, func, chan etc.
+		return &JSONSchema{}
+	}
+}
+
+func (g *generator) structSchema(t reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	g.fields(t, schema)
+	return schema
+}
+
+func (g *generator) fields(t reflect.Type, schema *JSONSchema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		// flatten embedded struct without name
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, schema)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema.Properties[name] = g.schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
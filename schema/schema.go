@@ -0,0 +1,260 @@
+// Copyright (c) nano Authors. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package schema describes the routes exposed by nano servers, includes route
+// codes, serializers, request, response and push payload types. The schema is
+// exported in JSON, which can be used to generate client stubs and detect
+// breaking changes between versions.
+package schema
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/env"
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/serialize"
+	"google.golang.org/protobuf/proto"
+)
+
+// Kind represents the kind of payload
+type Kind string
+
+// Payload kinds
+const (
+	Raw     Kind = "raw"     // raw bytes, not serialized by server
+	Typed   Kind = "typed"   // typed message serialized by route serializer
+	Unknown Kind = "unknown" // not declared
+)
+
+type (
+	// Schema describes all services of a server
+	Schema struct {
+		Version     string                 `json:"version,omitempty"`
+		Services    []*Service             `json:"services"`
+		Definitions map[string]*JSONSchema `json:"definitions,omitempty"`
+	}
+
+	// Service describes a component service
+	Service struct {
+		Name     string     `json:"name"`
+		Handlers []*Handler `json:"handlers"`
+		Pushes   []*Push    `json:"pushes,omitempty"`
+	}
+
+	// Handler describes a handler route
+	Handler struct {
+		Route      string     `json:"route"`
+		Code       uint16     `json:"code,omitempty"`
+		Serializer Serializer `json:"serializer"`
+		Request    *Type      `json:"request"`
+		Response   *Type      `json:"response"`
+	}
+
+	// Push describes a push route declared by service
+	Push struct {
+		Route      string     `json:"route"`
+		Serializer Serializer `json:"serializer"`
+		Payload    *Type      `json:"payload"`
+	}
+
+	// Serializer describes the serializer of route
+	Serializer struct {
+		Type uint16 `json:"type"`
+		Name string `json:"name"`
+	}
+
+	// Type describes a payload type
+	Type struct {
+		Kind      Kind        `json:"kind"`
+		GoType    string      `json:"goType,omitempty"`
+		GoPackage string      `json:"goPackage,omitempty"`
+		Proto     *ProtoRef   `json:"proto,omitempty"`
+		Schema    *JSONSchema `json:"schema,omitempty"`
+	}
+
+	// ProtoRef refers to a protobuf message
+	ProtoRef struct {
+		Message string `json:"message"`
+		File    string `json:"file,omitempty"`
+	}
+)
+
+var serializerNames = map[uint16]string{
+	message.JSON:        "json",
+	message.Protobuf:    "protobuf",
+	message.RawString:   "rawstring",
+	message.MessagePack: "msgpack",
+	message.CBOR:        "cbor",
+}
+
+var typeOfBytes = reflect.TypeOf(([]byte)(nil))
+
+// New returns the schema of services, services are sorted by name and
+// handlers are sorted by route
+func New(version string, services []*component.Service) *Schema {
+	g := newGenerator()
+	schema := &Schema{Version: version, Services: []*Service{}}
+	for _, s := range services {
+		service := &Service{Name: s.Name, Handlers: []*Handler{}}
+		for name, h := range s.Handlers {
+			handler := &Handler{
+				Route:      s.Name + "." + name,
+				Code:       h.Code,
+				Serializer: describeSerializer(h.Serializer),
+			}
+			if h.IsRawArg {
+				handler.Request = &Type{Kind: Raw}
+			} else {
+				handler.Request = g.describe(h.Type)
+			}
+			if h.Response != nil {
+				handler.Response = g.describe(h.Response)
+			} else {
+				handler.Response = &Type{Kind: Unknown}
+			}
+			service.Handlers = append(service.Handlers, handler)
+		}
+		sort.Slice(service.Handlers, func(i, j int) bool {
+			return service.Handlers[i].Route < service.Handlers[j].Route
+		})
+
+		for route, typ := range s.Pushes {
+			service.Pushes = append(service.Pushes, &Push{
+				Route:      route,
+				Serializer: describeSerializer(nil),
+				Payload:    g.describe(typ),
+			})
+		}
+		sort.Slice(service.Pushes, func(i, j int) bool {
+			return service.Pushes[i].Route < service.Pushes[j].Route
+		})
+
+		schema.Services = append(schema.Services, service)
+	}
+	sort.Slice(schema.Services, func(i, j int) bool {
+		return schema.Services[i].Name < schema.Services[j].Name
+	})
+
+	if len(g.definitions) > 0 {
+		schema.Definitions = g.definitions
+	}
+	return schema
+}
+
+// FromComponents extracts handlers of components and returns the schema of
+// them, which does not need a running server
+func FromComponents(version string, comps *component.Components) (*Schema, error) {
+	var services []*component.Service
+	for _, c := range comps.List() {
+		s := component.NewService(c.Comp, c.Opts)
+		if err := s.ExtractHandler(); err != nil {
+			return nil, err
+		}
+		services = append(services, s)
+	}
+	return New(version, services), nil
+}
+
+// Load reads schema from JSON file
+func Load(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema := &Schema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Save writes schema to JSON file
+func (s *Schema) Save(path string) error {
+	data, err := s.JSON()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// JSON returns the indented JSON encoding of schema
+func (s *Schema) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// Handler returns the handler description by route
+func (s *Schema) Handler(route string) (*Handler, bool) {
+	for _, service := range s.Services {
+		for _, h := range service.Handlers {
+			if h.Route == route {
+				return h, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Push returns the push description by route
+func (s *Schema) Push(route string) (*Push, bool) {
+	for _, service := range s.Services {
+		for _, p := range service.Pushes {
+			if p.Route == route {
+				return p, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func describeSerializer(s serialize.Serializer) Serializer {
+	if s == nil {
+		s = env.Serializer
+	}
+	typ := message.GetSerializerType(s)
+	name, ok := serializerNames[typ]
+	if !ok {
+		name = reflect.TypeOf(s).String()
+	}
+	return Serializer{Type: typ, Name: name}
+}
+
+func (g *generator) describe(t reflect.Type) *Type {
+	if t == typeOfBytes {
+		return &Type{Kind: Raw}
+	}
+	typ := &Type{Kind: Typed, GoType: t.String(), Schema: g.schemaOf(t)}
+	if t.Kind() == reflect.Ptr {
+		typ.GoPackage = t.Elem().PkgPath()
+	} else {
+		typ.GoPackage = t.PkgPath()
+	}
+	if pb, ok := reflect.Zero(t).Interface().(proto.Message); ok && t.Kind() == reflect.Ptr {
+		desc := pb.ProtoReflect().Descriptor()
+		typ.Proto = &ProtoRef{Message: string(desc.FullName())}
+		if file := desc.ParentFile(); file != nil {
+			typ.Proto.File = file.Path()
+		}
+	}
+	return typ
+}
@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aura-studio/nano/benchmark/testdata"
+	"github.com/aura-studio/nano/component"
+	"github.com/aura-studio/nano/serialize/json"
+	"github.com/aura-studio/nano/session"
+)
+
+type JoinRequest struct {
+	RoomID int64    `json:"roomId"`
+	Name   string   `json:"name,omitempty"`
+	Tags   []string `json:"tags"`
+	Parent *JoinRequest
+	secret string
+}
+
+type JoinResponse struct {
+	Members map[string]int `json:"members"`
+}
+
+type RoomComponent struct{ component.Base }
+
+func (c *RoomComponent) Join(s *session.Session, req *JoinRequest) error { return nil }
+
+func (c *RoomComponent) Ping(s *session.Session, ping *testdata.Ping) error { return nil }
+
+func (c *RoomComponent) Raw(s *session.Session, data []byte) error { return nil }
+
+func newSchema(t *testing.T, opts ...component.Option) *Schema {
+	comps := &component.Components{}
+	comps.Register(&RoomComponent{}, opts...)
+	s, err := FromComponents("1.0.0", comps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestFromComponents(t *testing.T) {
+	s := newSchema(t,
+		component.WithSerializer(json.NewSerializer()),
+		component.WithHandlerResponse(&JoinResponse{}, (*RoomComponent).Join),
+		component.WithPush("onJoin", &JoinResponse{}),
+	)
+
+	if len(s.Services) != 1 || s.Services[0].Name != "RoomComponent" {
+		t.Fatalf("unexpected services: %+v", s.Services)
+	}
+
+	join, ok := s.Handler("RoomComponent.Join")
+	if !ok {
+		t.Fatal("handler not found")
+	}
+	if join.Serializer.Name != "json" {
+		t.Fatalf("unexpected serializer: %+v", join.Serializer)
+	}
+	if join.Request.Kind != Typed || join.Request.GoType != "*schema.JoinRequest" {
+		t.Fatalf("unexpected request: %+v", join.Request)
+	}
+	def := s.Definitions[DefinitionName(join.Request.Schema.Ref)]
+	if def == nil {
+		t.Fatalf("definition not found: %s", join.Request.Schema.Ref)
+	}
+	var props []string
+	for prop := range def.Properties {
+		props = append(props, prop)
+	}
+	if len(props) != 4 || def.Properties["tags"].Items.Type != "string" ||
+		def.Properties["Parent"].Ref != join.Request.Schema.Ref {
+		t.Fatalf("unexpected properties: %+v", def.Properties)
+	}
+	if !reflect.DeepEqual(def.Required, []string{"roomId", "tags"}) {
+		t.Fatalf("unexpected required: %v", def.Required)
+	}
+	if join.Response.Kind != Typed || join.Response.GoType != "*schema.JoinResponse" {
+		t.Fatalf("unexpected response: %+v", join.Response)
+	}
+
+	ping, _ := s.Handler("RoomComponent.Ping")
+	if ping.Request.Proto == nil || ping.Request.Proto.Message != "testdata.Ping" {
+		t.Fatalf("unexpected proto ref: %+v", ping.Request.Proto)
+	}
+	if ping.Response.Kind != Unknown {
+		t.Fatalf("unexpected response: %+v", ping.Response)
+	}
+
+	raw, _ := s.Handler("RoomComponent.Raw")
+	if raw.Request.Kind != Raw {
+		t.Fatalf("unexpected request: %+v", raw.Request)
+	}
+
+	if p, ok := s.Push("onJoin"); !ok || p.Payload.GoType != "*schema.JoinResponse" {
+		t.Fatalf("unexpected push: %+v", p)
+	}
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Diff(s, loaded); len(changes) != 0 {
+		t.Fatalf("unexpected changes: %v", changes)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := newSchema(t, component.WithSerializer(json.NewSerializer()))
+	new := newSchema(t, component.WithSerializer(json.NewSerializer()))
+
+	join, _ := new.Handler("RoomComponent.Join")
+	def := new.Definitions[DefinitionName(join.Request.Schema.Ref)]
+	def.Properties["level"] = &JSONSchema{Type: "integer", Format: "int"}
+	def.Required = append(def.Required, "level")
+	def.Properties["roomId"] = &JSONSchema{Type: "string"}
+	new.Services[0].Handlers = new.Services[0].Handlers[:2]
+
+	changes := Diff(old, new)
+	expects := []Change{
+		{Route: "RoomComponent.Join", Breaking: true, Message: "request.level added"},
+		{Route: "RoomComponent.Join", Breaking: true, Message: "request.roomId type changed from integer(int64) to string"},
+		{Route: "RoomComponent.Raw", Breaking: true, Message: "handler removed"},
+	}
+	if !reflect.DeepEqual(changes, expects) {
+		t.Fatalf("expect: %v, got: %v", expects, changes)
+	}
+	if !Breaking(changes) {
+		t.Fatal("expect breaking changes")
+	}
+}
@@ -44,3 +44,13 @@ func (r *Router) Find(service string) (string, bool) {
 	}
 	return v.(string), true
 }
+
+// Bindings returns all services and their bound addresses
+func (r *Router) Bindings() map[string]string {
+	bindings := make(map[string]string)
+	r.routes.Range(func(k, v interface{}) bool {
+		bindings[k.(string)] = v.(string)
+		return true
+	})
+	return bindings
+}
@@ -25,6 +25,8 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/aura-studio/nano/log"
+	"github.com/aura-studio/nano/message"
 	"github.com/mohae/deepcopy"
 )
 
@@ -39,6 +41,12 @@ type NetworkEntity interface {
 	RemoteAddr() net.Addr
 }
 
+// PriorityPusher is implemented by network entities which push messages in
+// priority lanes, the entities without it push messages by Push
+type PriorityPusher interface {
+	PushPriority(route string, v interface{}, p message.Priority) error
+}
+
 // EventCallback is the func called after event trigged
 type EventCallback func(*Session, ...interface{})
 
@@ -90,6 +98,16 @@ func (s *Session) Push(route string, v interface{}) error {
 	return s.entity.Push(route, v)
 }
 
+// PushPriority pushes message to client in specified priority lane, the
+// route priority is used if p is message.PriorityDefault, the message is pushed
+// by Push if the network entity is not a PriorityPusher
+func (s *Session) PushPriority(route string, v interface{}, p message.Priority) error {
+	if pusher, ok := s.entity.(PriorityPusher); ok {
+		return pusher.PushPriority(route, v, p)
+	}
+	return s.entity.Push(route, v)
+}
+
 // Response message to client
 func (s *Session) Response(route string, v interface{}) error {
 	return s.entity.Response(route, v)
@@ -127,6 +145,11 @@ func (s *Session) BindShortVer(shortVer uint32) {
 	s.shortVer = shortVer
 }
 
+// Logger returns the structured logger bound with the session id, uid and version
+func (s *Session) Logger() log.StructuredLogger {
+	return log.With("sid", s.id, "uid", s.UID(), "version", s.version)
+}
+
 // LastMid returns the last message id
 func (s *Session) LastMid() uint64 {
 	return s.entity.LastMid()
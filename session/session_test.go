@@ -1,6 +1,51 @@
 package session
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/aura-studio/nano/message"
+	"github.com/aura-studio/nano/mock"
+)
+
+// pushEntity records the routes pushed without priority lanes
+type pushEntity struct {
+	*mock.NetworkEntity
+	pushed []string
+}
+
+func (e *pushEntity) Push(route string, _ interface{}) error {
+	e.pushed = append(e.pushed, route)
+	return nil
+}
+
+// priorityEntity records the priorities of messages pushed
+type priorityEntity struct {
+	pushEntity
+	priorities []message.Priority
+}
+
+func (e *priorityEntity) PushPriority(route string, _ interface{}, p message.Priority) error {
+	e.priorities = append(e.priorities, p)
+	return nil
+}
+
+func TestSession_PushPriority(t *testing.T) {
+	entity := &pushEntity{NetworkEntity: mock.NewNetworkEntity()}
+	if err := New(entity, 1).PushPriority("onChat", nil, message.PriorityCritical); err != nil {
+		t.Fatal(err)
+	}
+	if len(entity.pushed) != 1 || entity.pushed[0] != "onChat" {
+		t.Fatalf("message is not pushed by Push: %v", entity.pushed)
+	}
+
+	pusher := &priorityEntity{pushEntity: pushEntity{NetworkEntity: mock.NewNetworkEntity()}}
+	if err := New(pusher, 2).PushPriority("onChat", nil, message.PriorityCritical); err != nil {
+		t.Fatal(err)
+	}
+	if len(pusher.priorities) != 1 || pusher.priorities[0] != message.PriorityCritical || len(pusher.pushed) != 0 {
+		t.Fatalf("message is not pushed in priority lane: %v", pusher.priorities)
+	}
+}
 
 func TestNewSession(t *testing.T) {
 	s := New(nil, 1)
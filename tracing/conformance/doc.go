@@ -0,0 +1,6 @@
+// Package conformance tests the OTLP/HTTP JSON encoding of tracing exporters
+// by the unmarshaler of the OpenTelemetry collector. It is a separate module
+// because the collector requires newer Go versions than nano, run it by:
+//
+//	cd tracing/conformance && go test ./...
+package conformance
@@ -0,0 +1,91 @@
+package conformance
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aura-studio/nano/tracing"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type: %s", ct)
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	start := time.Unix(1700000000, 123)
+	span := &tracing.SpanData{
+		Name:         "nano.receive",
+		Kind:         tracing.KindServer,
+		TraceID:      tracing.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:       tracing.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		ParentSpanID: tracing.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+		Start:        start,
+		End:          start.Add(time.Millisecond),
+		Attributes: map[string]interface{}{
+			"nano.route":      "Room.Join",
+			"nano.uid":        int64(1001),
+			"nano.compressed": true,
+			"nano.ratio":      0.5,
+		},
+		Error: "timeout",
+	}
+	if err := tracing.NewOTLPExporter(server.URL, "gate").Export([]*tracing.SpanData{span}); err != nil {
+		t.Fatal(err)
+	}
+
+	traces, err := (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(body)
+	if err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+	if traces.SpanCount() != 1 {
+		t.Fatalf("%d spans exported: %s", traces.SpanCount(), body)
+	}
+
+	rs := traces.ResourceSpans().At(0)
+	if v, ok := rs.Resource().Attributes().Get("service.name"); !ok || v.Str() != "gate" {
+		t.Fatalf("unexpected resource: %s", body)
+	}
+	ss := rs.ScopeSpans().At(0)
+	if ss.Scope().Name() != "github.com/aura-studio/nano" {
+		t.Fatalf("unexpected scope: %s", ss.Scope().Name())
+	}
+
+	s := ss.Spans().At(0)
+	if s.Name() != span.Name || s.Kind() != ptrace.SpanKindServer {
+		t.Fatalf("unexpected span: %s", body)
+	}
+	if s.TraceID() != pcommon.TraceID(span.TraceID) || s.SpanID() != pcommon.SpanID(span.SpanID) ||
+		s.ParentSpanID() != pcommon.SpanID(span.ParentSpanID) {
+		t.Fatalf("unexpected ids: %s", body)
+	}
+	if !s.StartTimestamp().AsTime().Equal(span.Start) || !s.EndTimestamp().AsTime().Equal(span.End) {
+		t.Fatalf("unexpected timestamps: %s", body)
+	}
+	if s.Status().Code() != ptrace.StatusCodeError || s.Status().Message() != "timeout" {
+		t.Fatalf("unexpected status: %s", body)
+	}
+
+	attrs := s.Attributes()
+	if v, _ := attrs.Get("nano.route"); v.Type() != pcommon.ValueTypeStr || v.Str() != "Room.Join" {
+		t.Fatalf("unexpected route: %s", body)
+	}
+	if v, _ := attrs.Get("nano.uid"); v.Type() != pcommon.ValueTypeInt || v.Int() != 1001 {
+		t.Fatalf("unexpected uid: %s", body)
+	}
+	if v, _ := attrs.Get("nano.compressed"); v.Type() != pcommon.ValueTypeBool || !v.Bool() {
+		t.Fatalf("unexpected compressed: %s", body)
+	}
+	if v, _ := attrs.Get("nano.ratio"); v.Type() != pcommon.ValueTypeDouble || v.Double() != 0.5 {
+		t.Fatalf("unexpected ratio: %s", body)
+	}
+}
@@ -0,0 +1,371 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aura-studio/nano/log"
+)
+
+const (
+	batchSize     = 512
+	batchInterval = time.Second
+	queueBacklog  = 1 << 11
+)
+
+// Exporter exports ended spans in batches
+type Exporter interface {
+	Export(spans []*SpanData) error
+}
+
+type batcher struct {
+	exporter Exporter
+	chSpans  chan *SpanData
+	chFlush  chan chan struct{}
+	chDie    chan struct{}
+	chExit   chan struct{}
+}
+
+var (
+	mu      sync.RWMutex
+	current *batcher
+	ratio   uint64 = math.Float64bits(1)
+)
+
+// SetExporter enables tracing with the exporter, or disables tracing if the
+// exporter is nil, spans of the previous exporter are flushed
+func SetExporter(exporter Exporter) {
+	mu.Lock()
+	old := current
+	current = nil
+	if exporter != nil {
+		current = &batcher{
+			exporter: exporter,
+			chSpans:  make(chan *SpanData, queueBacklog),
+			chFlush:  make(chan chan struct{}),
+			chDie:    make(chan struct{}),
+			chExit:   make(chan struct{}),
+		}
+		go current.run()
+	}
+	mu.Unlock()
+
+	if old != nil {
+		close(old.chDie)
+		<-old.chExit
+	}
+}
+
+// Enabled reports whether tracing is enabled
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil
+}
+
+// SetSampleRatio sets the ratio of new traces to be sampled, spans with a
+// parent follow the sampling decision of the parent
+func SetSampleRatio(r float64) {
+	atomic.StoreUint64(&ratio, math.Float64bits(r))
+}
+
+// SampleRatio returns the ratio of new traces to be sampled
+func SampleRatio() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&ratio))
+}
+
+// Flush exports all ended spans and waits for completion
+func Flush() {
+	mu.RLock()
+	b := current
+	mu.RUnlock()
+	if b == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	select {
+	case b.chFlush <- done:
+		<-done
+	case <-b.chExit:
+	}
+}
+
+func export(span *SpanData) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return
+	}
+	select {
+	case current.chSpans <- span:
+	default:
+		// drop spans rather than block messages
+	}
+}
+
+func (b *batcher) run() {
+	ticker := time.NewTicker(batchInterval)
+	defer func() {
+		ticker.Stop()
+		close(b.chExit)
+	}()
+
+	spans := make([]*SpanData, 0, batchSize)
+	flush := func() {
+		// take queued spans up to a batch
+		for n := len(b.chSpans); n > 0 && len(spans) < batchSize; n-- {
+			spans = append(spans, <-b.chSpans)
+		}
+		if len(spans) == 0 {
+			return
+		}
+		if err := b.exporter.Export(spans); err != nil {
+			log.Errorf("nano/tracing: export %d spans error: %v", len(spans), err)
+		}
+		spans = make([]*SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case span := <-b.chSpans:
+			spans = append(spans, span)
+			if len(spans) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case done := <-b.chFlush:
+			flush()
+			for len(b.chSpans) > 0 {
+				flush()
+			}
+			close(done)
+		case <-b.chDie:
+			flush()
+			for len(b.chSpans) > 0 {
+				flush()
+			}
+			return
+		}
+	}
+}
+
+// StdoutExporter writes spans as JSON lines
+type StdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutExporter returns an exporter writes spans to w, os.Stdout is used
+// if w is nil
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &StdoutExporter{w: w}
+}
+
+type stdoutSpan struct {
+	Name         string                 `json:"name"`
+	Kind         Kind                   `json:"kind"`
+	TraceID      string                 `json:"traceId"`
+	SpanID       string                 `json:"spanId"`
+	ParentSpanID string                 `json:"parentSpanId,omitempty"`
+	Start        time.Time              `json:"start"`
+	Duration     string                 `json:"duration"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// Export implements the Exporter interface
+func (e *StdoutExporter) Export(spans []*SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		out := stdoutSpan{
+			Name:       s.Name,
+			Kind:       s.Kind,
+			TraceID:    s.TraceID.String(),
+			SpanID:     s.SpanID.String(),
+			Start:      s.Start,
+			Duration:   s.End.Sub(s.Start).String(),
+			Attributes: s.Attributes,
+			Error:      s.Error,
+		}
+		if s.ParentSpanID != (SpanID{}) {
+			out.ParentSpanID = s.ParentSpanID.String()
+		}
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultOTLPEndpoint is the traces endpoint of a local OpenTelemetry collector
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// OTLPExporter posts spans to an OpenTelemetry collector with the OTLP/HTTP
+// protocol in JSON encoding. The OpenTelemetry SDK is not used since it requires
+// newer Go versions than nano, the encoding is tested by the collector in the
+// tracing/conformance module.
+type OTLPExporter struct {
+	Endpoint    string
+	ServiceName string
+	Header      http.Header
+	Client      *http.Client
+}
+
+// NewOTLPExporter returns an exporter posts spans to endpoint, the default
+// local collector endpoint is used if endpoint is empty
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	if endpoint == "" {
+		endpoint = DefaultOTLPEndpoint
+	}
+	return &OTLPExporter{
+		Endpoint:    endpoint,
+		ServiceName: serviceName,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type (
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              Kind            `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+
+	otlpScopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+)
+
+func otlpAttributeOf(key string, v interface{}) otlpAttribute {
+	attr := otlpAttribute{Key: key}
+	switch x := v.(type) {
+	case string:
+		attr.Value.StringValue = &x
+	case bool:
+		attr.Value.BoolValue = &x
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(x)
+		attr.Value.IntValue = &s
+	case float32:
+		f := float64(x)
+		attr.Value.DoubleValue = &f
+	case float64:
+		attr.Value.DoubleValue = &x
+	default:
+		s := fmt.Sprint(x)
+		attr.Value.StringValue = &s
+	}
+	return attr
+}
+
+// Export implements the Exporter interface
+func (e *OTLPExporter) Export(spans []*SpanData) error {
+	ss := otlpScopeSpans{}
+	ss.Scope.Name = "github.com/aura-studio/nano"
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		if s.ParentSpanID != (SpanID{}) {
+			span.ParentSpanID = s.ParentSpanID.String()
+		}
+		for k, v := range s.Attributes {
+			span.Attributes = append(span.Attributes, otlpAttributeOf(k, v))
+		}
+		if s.Error != "" {
+			// STATUS_CODE_ERROR
+			span.Status = &otlpStatus{Code: 2, Message: s.Error}
+		}
+		ss.Spans = append(ss.Spans, span)
+	}
+	rs := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{ss}}
+	rs.Resource.Attributes = []otlpAttribute{otlpAttributeOf("service.name", e.ServiceName)}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{rs}})
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest(http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("tracing: otlp endpoint responds %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+// Package tracing records spans of messages across gates and backends, the
+// trace context is propagated in the W3C traceparent format and spans can be
+// exported to stdout or an OpenTelemetry collector with the OTLP/HTTP protocol.
+// Tracing is disabled until an exporter is set.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Kind is the kind of span, the values are the same as OpenTelemetry
+type Kind int
+
+// Span kinds
+const (
+	KindInternal Kind = iota + 1
+	KindServer
+	KindClient
+	KindProducer
+	KindConsumer
+)
+
+// ErrInvalidTraceParent represents an invalid traceparent header
+var ErrInvalidTraceParent = errors.New("tracing: invalid traceparent")
+
+type (
+	// TraceID is the id of a trace
+	TraceID [16]byte
+
+	// SpanID is the id of a span
+	SpanID [8]byte
+
+	// SpanContext identifies a span in a trace
+	SpanContext struct {
+		TraceID TraceID
+		SpanID  SpanID
+		Sampled bool
+	}
+
+	// SpanData is the recorded data of an ended span
+	SpanData struct {
+		Name         string
+		Kind         Kind
+		TraceID      TraceID
+		SpanID       SpanID
+		ParentSpanID SpanID
+		Start        time.Time
+		End          time.Time
+		Attributes   map[string]interface{}
+		Error        string
+	}
+
+	// Span is an operation in a trace, a nil span is valid and records nothing
+	Span struct {
+		mu      sync.Mutex
+		data    SpanData
+		sampled bool
+		ended   bool
+	}
+
+	spanContextKey struct{}
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the trace id and span id are not zero
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// TraceParent formats the span context in the W3C traceparent format, returns
+// an empty string if the span context is invalid
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceParent parses a span context in the W3C traceparent format
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+	// version-traceid-spanid-flags
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' || s[:2] == "ff" {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(s[3:35])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(s[36:52])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(s[53:55])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceParent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// ContextWithSpanContext returns a context carrying the span context as the
+// parent of spans started by the context
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// ContextWithTraceParent is like ContextWithSpanContext with a traceparent,
+// the context is returned as it is if the traceparent is invalid
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	sc, err := ParseTraceParent(traceParent)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// SpanContextFromContext returns the span context carried by the context
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+var (
+	muRand sync.Mutex
+	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func newIDs(traceID *TraceID, spanID *SpanID) {
+	muRand.Lock()
+	defer muRand.Unlock()
+	if traceID != nil {
+		random.Read(traceID[:])
+	}
+	random.Read(spanID[:])
+}
+
+func sample() bool {
+	ratio := SampleRatio()
+	if ratio >= 1 {
+		return true
+	}
+	muRand.Lock()
+	defer muRand.Unlock()
+	return random.Float64() < ratio
+}
+
+// Start starts a span which is the child of the span carried by ctx, a new
+// trace is started if there is no parent. It returns a nil span if tracing is
+// disabled.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+	span := &Span{data: SpanData{Name: name, Kind: kind, Start: time.Now()}}
+	if parent.IsValid() {
+		span.data.TraceID = parent.TraceID
+		span.data.ParentSpanID = parent.SpanID
+		span.sampled = parent.Sampled
+		newIDs(nil, &span.data.SpanID)
+	} else {
+		span.sampled = sample()
+		newIDs(&span.data.TraceID, &span.data.SpanID)
+	}
+	return ContextWithSpanContext(ctx, span.Context()), span
+}
+
+// Context returns the span context of the span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID, Sampled: s.sampled}
+}
+
+// SetAttribute sets an attribute of the span, the value should be a string,
+// bool, integer or float
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the span failed
+func (s *Span) SetError(err error) {
+	if s == nil || !s.sampled || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End ends the span and exports it if sampled, only the first call takes effect
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.sampled {
+		export(&data)
+	}
+}
+
+func (s *Span) String() string {
+	if s == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%s(%s)", s.data.Name, s.Context().TraceParent())
+}
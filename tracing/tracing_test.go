@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (e *memoryExporter) Export(spans []*SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestTraceParent(t *testing.T) {
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceParent(tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected span context: %+v", sc)
+	}
+	if sc.TraceParent() != tp {
+		t.Fatalf("unexpected traceparent: %s", sc.TraceParent())
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceParent(invalid); err != ErrInvalidTraceParent {
+			t.Fatalf("expect invalid traceparent: %s", invalid)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	if _, span := Start(context.Background(), "disabled", KindInternal); span != nil {
+		t.Fatal("expect nil span while tracing is disabled")
+	}
+
+	exporter := &memoryExporter{}
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	ctx, root := Start(context.Background(), "root", KindServer)
+	_, child := Start(ctx, "child", KindInternal)
+	child.SetAttribute("nano.route", "Room.Join")
+	child.SetError(errors.New("failed"))
+	child.End()
+	child.End()
+	root.End()
+
+	// remote parent
+	remote := ContextWithTraceParent(context.Background(), root.Context().TraceParent())
+	_, span := Start(remote, "remote", KindServer)
+	span.End()
+	Flush()
+
+	if len(exporter.spans) != 3 {
+		t.Fatalf("unexpected spans: %d", len(exporter.spans))
+	}
+	c, r, s := exporter.spans[0], exporter.spans[1], exporter.spans[2]
+	if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID || r.ParentSpanID != (SpanID{}) {
+		t.Fatalf("unexpected child: %+v, root: %+v", c, r)
+	}
+	if c.Attributes["nano.route"] != "Room.Join" || c.Error != "failed" {
+		t.Fatalf("unexpected child: %+v", c)
+	}
+	if s.TraceID != r.TraceID || s.ParentSpanID != r.SpanID {
+		t.Fatalf("unexpected remote child: %+v", s)
+	}
+
+	SetSampleRatio(0)
+	defer SetSampleRatio(1)
+	_, span = Start(context.Background(), "unsampled", KindServer)
+	if span.Context().Sampled || !span.Context().IsValid() {
+		t.Fatalf("unexpected span context: %+v", span.Context())
+	}
+	span.End()
+	Flush()
+	if len(exporter.spans) != 3 {
+		t.Fatalf("unexpected spans: %d", len(exporter.spans))
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	span := &SpanData{Name: "nano.handler", Kind: KindInternal, Attributes: map[string]interface{}{"nano.route": "Room.Join"}}
+	span.TraceID[0], span.SpanID[0] = 1, 2
+	if err := NewStdoutExporter(buf).Export([]*SpanData{span}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"name":"nano.handler"`) || !strings.Contains(out, `"traceId":"01000000000000000000000000000000"`) ||
+		!strings.Contains(out, `"nano.route":"Room.Join"`) || strings.Contains(out, "parentSpanId") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+	}))
+	defer server.Close()
+
+	span := &SpanData{Name: "nano.forward", Kind: KindClient, Error: "unavailable",
+		Attributes: map[string]interface{}{"nano.uid": int64(1)}}
+	span.TraceID[0], span.SpanID[0], span.ParentSpanID[0] = 1, 2, 3
+	if err := NewOTLPExporter(server.URL+"/v1/traces", "gate").Export([]*SpanData{span}); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	resource := rs["resource"].(map[string]interface{})["attributes"].([]interface{})[0].(map[string]interface{})
+	if resource["key"] != "service.name" || resource["value"].(map[string]interface{})["stringValue"] != "gate" {
+		t.Fatalf("unexpected resource: %v", resource)
+	}
+	s := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+	if s["name"] != "nano.forward" || s["kind"] != float64(KindClient) || s["parentSpanId"] != "0300000000000000" {
+		t.Fatalf("unexpected span: %v", s)
+	}
+	attr := s["attributes"].([]interface{})[0].(map[string]interface{})
+	if attr["value"].(map[string]interface{})["intValue"] != "1" {
+		t.Fatalf("unexpected attribute: %v", attr)
+	}
+	if s["status"].(map[string]interface{})["code"] != float64(2) {
+		t.Fatalf("unexpected status: %v", s["status"])
+	}
+}
@@ -0,0 +1,482 @@
+package upgrader
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTP transports
+const (
+	TransportPolling = "polling" // long-polling, data is responded in body
+	TransportSSE     = "sse"     // server-sent events, data is base64 encoded
+)
+
+var (
+	// ErrSessionNotFound represents the session id is unknown or expired
+	ErrSessionNotFound = errors.New("upgrader: session not found")
+	// ErrOriginNotAllowed represents the origin of request is not allowed
+	ErrOriginNotAllowed = errors.New("upgrader: origin not allowed")
+)
+
+// HTTP is an Upgrader for restrictive networks where WebSocket is not available,
+// the client opens a session, sends the codec stream by POST and receives the
+// codec stream by long-polling or server-sent events:
+//
+//	POST   /path?transport=polling|sse  open a session, responds {"sid":"...","transport":"..."}
+//	POST   /path?sid=...                send data in body
+//	GET    /path?sid=...                receive data, by long-polling or event stream
+//	DELETE /path?sid=...                close the session
+//
+// Only the request opening a session returns a new connection, other requests
+// are served by the upgrader and return a nil connection.
+type HTTP struct {
+	// AllowedOrigins is the list of origins allowed to connect, only the same
+	// origin is allowed if empty and all origins are allowed by "*"
+	AllowedOrigins []string
+
+	// PollTimeout is the max duration of a long-polling request, and the
+	// interval of keepalive comments in event stream, 25s if zero
+	PollTimeout time.Duration
+
+	// SessionTimeout is the duration after which a session is closed if no
+	// receiving request is attached, 60s if zero
+	SessionTimeout time.Duration
+
+	// MaxBodySize is the max size of data sent in one request, 64KB if zero
+	MaxBodySize int64
+
+	// MaxBufferSize is the max size of data queued until received by client,
+	// writing to a session blocks while the buffer is full, 256KB if zero
+	MaxBufferSize int
+
+	mu       sync.Mutex
+	sessions map[string]*httpConn
+	expiring bool // whether the expire goroutine is running
+}
+
+// Default settings of HTTP upgrader
+const (
+	defaultPollTimeout    = 25 * time.Second
+	defaultSessionTimeout = 60 * time.Second
+	defaultMaxBodySize    = 64 * 1024
+	defaultMaxBufferSize  = 256 * 1024
+)
+
+// NewHTTP returns a HTTP upgrader with default timeouts
+func NewHTTP() *HTTP {
+	return &HTTP{
+		PollTimeout:    defaultPollTimeout,
+		SessionTimeout: defaultSessionTimeout,
+		MaxBodySize:    defaultMaxBodySize,
+		MaxBufferSize:  defaultMaxBufferSize,
+	}
+}
+
+func (u *HTTP) pollTimeout() time.Duration {
+	if u.PollTimeout <= 0 {
+		return defaultPollTimeout
+	}
+	return u.PollTimeout
+}
+
+func (u *HTTP) sessionTimeout() time.Duration {
+	if u.SessionTimeout <= 0 {
+		return defaultSessionTimeout
+	}
+	return u.SessionTimeout
+}
+
+func (u *HTTP) maxBodySize() int64 {
+	if u.MaxBodySize <= 0 {
+		return defaultMaxBodySize
+	}
+	return u.MaxBodySize
+}
+
+func (u *HTTP) maxBufferSize() int {
+	if u.MaxBufferSize <= 0 {
+		return defaultMaxBufferSize
+	}
+	return u.MaxBufferSize
+}
+
+// Upgrade serves the request, and returns the connection if a session is opened
+func (u *HTTP) Upgrade(w http.ResponseWriter, r *http.Request, params map[string]string) (net.Conn, error) {
+	if !checkOrigin(u.AllowedOrigins, r) {
+		http.Error(w, ErrOriginNotAllowed.Error(), http.StatusForbidden)
+		return nil, ErrOriginNotAllowed
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		// credentials are only allowed for origins listed explicitly
+		if allowAll(u.AllowedOrigins) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Vary", "Origin")
+		}
+	}
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return nil, nil
+	}
+
+	sid := r.URL.Query().Get("sid")
+	if sid == "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "session id required", http.StatusBadRequest)
+			return nil, fmt.Errorf("upgrader: unexpected method %s without session", r.Method)
+		}
+		return u.open(w, r)
+	}
+
+	u.mu.Lock()
+	c, ok := u.sessions[sid]
+	u.mu.Unlock()
+	if !ok {
+		http.Error(w, ErrSessionNotFound.Error(), http.StatusNotFound)
+		return nil, ErrSessionNotFound
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		return nil, u.receive(w, r, c)
+	case http.MethodGet:
+		if c.transport == TransportSSE {
+			u.stream(w, r, c)
+		} else {
+			u.poll(w, r, c)
+		}
+		return nil, nil
+	case http.MethodDelete:
+		c.Close()
+		w.WriteHeader(http.StatusNoContent)
+		return nil, nil
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("upgrader: unexpected method %s", r.Method)
+	}
+}
+
+// open creates a new session
+func (u *HTTP) open(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	transport := r.URL.Query().Get("transport")
+	if transport == "" {
+		transport = TransportPolling
+	}
+	if transport != TransportPolling && transport != TransportSSE {
+		http.Error(w, "unsupported transport", http.StatusBadRequest)
+		return nil, fmt.Errorf("upgrader: unsupported transport %s", transport)
+	}
+
+	sid, err := newSessionID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+
+	c := newHTTPConn(sid, transport, r.RemoteAddr, u.maxBufferSize(), u.remove)
+	u.mu.Lock()
+	if u.sessions == nil {
+		u.sessions = make(map[string]*httpConn)
+	}
+	u.sessions[sid] = c
+	if !u.expiring {
+		u.expiring = true
+		go u.expire()
+	}
+	u.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"sid": sid, "transport": transport}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Opens reports whether the request opens a session
+func (u *HTTP) Opens(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.URL.Query().Get("sid") == ""
+}
+
+func (u *HTTP) remove(sid string) {
+	u.mu.Lock()
+	delete(u.sessions, sid)
+	u.mu.Unlock()
+}
+
+// expire closes sessions which have no receiving request attached in timeout,
+// it returns if no session remains, and is started again by the next session
+func (u *HTTP) expire() {
+	timeout := u.sessionTimeout()
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		var expired []*httpConn
+		u.mu.Lock()
+		if len(u.sessions) == 0 {
+			u.expiring = false
+			u.mu.Unlock()
+			return
+		}
+		for _, c := range u.sessions {
+			if c.expired(timeout) {
+				expired = append(expired, c)
+			}
+		}
+		u.mu.Unlock()
+		for _, c := range expired {
+			c.Close()
+		}
+	}
+}
+
+// receive feeds the request body to the connection
+func (u *HTTP) receive(w http.ResponseWriter, r *http.Request, c *httpConn) error {
+	max := u.maxBodySize()
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+	if int64(len(data)) > max {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return fmt.Errorf("upgrader: request body exceeds %d bytes", max)
+	}
+	if _, err := c.pw.Write(data); err != nil {
+		http.Error(w, err.Error(), http.StatusGone)
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// poll responds pending data, or waits until data is written or timeout
+func (u *HTTP) poll(w http.ResponseWriter, r *http.Request, c *httpConn) {
+	c.attach()
+	defer c.detach()
+
+	timer := time.NewTimer(u.pollTimeout())
+	defer timer.Stop()
+	for {
+		if data := c.drain(); len(data) > 0 {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(data)
+			return
+		}
+		select {
+		case <-c.notify:
+		case <-timer.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-c.die:
+			w.WriteHeader(http.StatusGone)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// stream sends data in server-sent events until the session is closed
+func (u *HTTP) stream(w http.ResponseWriter, r *http.Request, c *httpConn) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c.attach()
+	defer c.detach()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(u.pollTimeout())
+	defer ticker.Stop()
+	for {
+		if data := c.drain(); len(data) > 0 {
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", base64.StdEncoding.EncodeToString(data)); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+		select {
+		case <-c.notify:
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-c.die:
+			io.WriteString(w, "event: close\ndata:\n\n")
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// httpAddr is the remote address of a HTTP session
+type httpAddr string
+
+func (a httpAddr) Network() string { return "http" }
+func (a httpAddr) String() string  { return string(a) }
+
+// httpConn is a connection composed of HTTP requests, data sent by client is
+// read from a pipe, and data written is queued until received by client, the
+// writer is blocked while the queue is full, as a TCP connection is blocked by
+// a slow client
+type httpConn struct {
+	sid       string
+	transport string
+	remote    httpAddr
+	pr        *io.PipeReader
+	pw        *io.PipeWriter
+	onClose   func(sid string)
+	maxBuffer int
+
+	mu       sync.Mutex
+	outgoing []byte
+	lastSeen time.Time
+	attached int32
+
+	notify    chan struct{}
+	drained   chan struct{}
+	die       chan struct{}
+	closeOnce sync.Once
+}
+
+func newHTTPConn(sid, transport, remote string, maxBuffer int, onClose func(sid string)) *httpConn {
+	pr, pw := io.Pipe()
+	return &httpConn{
+		sid:       sid,
+		transport: transport,
+		remote:    httpAddr(remote),
+		pr:        pr,
+		pw:        pw,
+		onClose:   onClose,
+		maxBuffer: maxBuffer,
+		lastSeen:  time.Now(),
+		notify:    make(chan struct{}, 1),
+		drained:   make(chan struct{}, 1),
+		die:       make(chan struct{}),
+	}
+}
+
+func (c *httpConn) attach() {
+	atomic.AddInt32(&c.attached, 1)
+}
+
+func (c *httpConn) detach() {
+	c.mu.Lock()
+	c.lastSeen = time.Now()
+	c.mu.Unlock()
+	atomic.AddInt32(&c.attached, -1)
+}
+
+func (c *httpConn) expired(timeout time.Duration) bool {
+	if atomic.LoadInt32(&c.attached) > 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Since(c.lastSeen) > timeout
+}
+
+// drain returns and clears the pending data
+func (c *httpConn) drain() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := c.outgoing
+	c.outgoing = nil
+	if len(data) > 0 {
+		select {
+		case c.drained <- struct{}{}:
+		default:
+		}
+	}
+	return data
+}
+
+// Read reads the data sent by client
+func (c *httpConn) Read(b []byte) (int, error) {
+	return c.pr.Read(b)
+}
+
+// Write queues data until received by client, it blocks until the data is
+// received if the queue is full, data larger than the max buffer size is
+// queued when the queue is empty
+func (c *httpConn) Write(b []byte) (int, error) {
+	for {
+		select {
+		case <-c.die:
+			return 0, io.ErrClosedPipe
+		default:
+		}
+
+		c.mu.Lock()
+		if len(c.outgoing) == 0 || len(c.outgoing)+len(b) <= c.maxBuffer {
+			c.outgoing = append(c.outgoing, b...)
+			c.mu.Unlock()
+			break
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.drained:
+		case <-c.die:
+			return 0, io.ErrClosedPipe
+		}
+	}
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return len(b), nil
+}
+
+// Close closes the session
+func (c *httpConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.die)
+		c.pw.CloseWithError(io.EOF)
+		c.onClose(c.sid)
+	})
+	return nil
+}
+
+func (c *httpConn) LocalAddr() net.Addr  { return httpAddr("") }
+func (c *httpConn) RemoteAddr() net.Addr { return c.remote }
+
+// Deadlines are not supported, the session is expired by the upgrader
+func (c *httpConn) SetDeadline(t time.Time) error      { return nil }
+func (c *httpConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *httpConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,238 @@
+package upgrader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openSession(t *testing.T, url, transport string) string {
+	resp, err := http.Post(url+"?transport="+transport, "application/octet-stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result["transport"] != transport || result["sid"] == "" {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	return result["sid"]
+}
+
+func send(t *testing.T, url, sid, data string) {
+	resp, err := http.Post(url+"?sid="+sid, "application/octet-stream", strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPPolling(t *testing.T) {
+	u := NewHTTP()
+	u.PollTimeout = 100 * time.Millisecond
+	server := serve(u)
+	defer server.Close()
+
+	sid := openSession(t, server.URL, TransportPolling)
+
+	// nothing to receive
+	resp, err := http.Get(server.URL + "?sid=" + sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+
+	send(t, server.URL, sid, "hello")
+	resp, err = http.Get(server.URL + "?sid=" + sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "hello" {
+		t.Fatalf("unexpected data: %s", data)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"?sid="+sid, nil)
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp, err = http.Get(server.URL + "?sid=" + sid); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPEventStream(t *testing.T) {
+	u := NewHTTP()
+	server := serve(u)
+	defer server.Close()
+
+	sid := openSession(t, server.URL, TransportSSE)
+	resp, err := http.Get(server.URL + "?sid=" + sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", ct)
+	}
+
+	send(t, server.URL, sid, "hello")
+	reader := bufio.NewReader(resp.Body)
+	var data []byte
+	for !bytes.Equal(data, []byte("hello")) {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(line, "data: ")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+	}
+}
+
+func TestHTTPDefaults(t *testing.T) {
+	u := &HTTP{}
+	server := serve(u)
+	defer server.Close()
+
+	sid := openSession(t, server.URL, TransportPolling)
+	send(t, server.URL, sid, "hello")
+	resp, err := http.Get(server.URL + "?sid=" + sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "hello" {
+		t.Fatalf("unexpected data: %s", data)
+	}
+}
+
+func TestHTTPExpire(t *testing.T) {
+	u := &HTTP{SessionTimeout: 40 * time.Millisecond}
+	server := serve(u)
+	defer server.Close()
+
+	sid := openSession(t, server.URL, TransportPolling)
+	expiring := func() bool {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		return u.expiring
+	}
+	deadline := time.Now().Add(time.Second)
+	for expiring() {
+		if time.Now().After(deadline) {
+			t.Fatal("expire goroutine is not stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := http.Get(server.URL + "?sid=" + sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+
+	// started again by the next session
+	openSession(t, server.URL, TransportPolling)
+	if !expiring() {
+		t.Fatal("expire goroutine is not started")
+	}
+}
+
+func TestHTTPCORS(t *testing.T) {
+	u := NewHTTP()
+	server := serve(u)
+	defer server.Close()
+
+	preflight := func(origin string) *http.Response {
+		req, _ := http.NewRequest(http.MethodOptions, server.URL, nil)
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := preflight("https://evil.com"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross origin responds %d", resp.StatusCode)
+	}
+	if resp := preflight(server.URL); resp.Header.Get("Access-Control-Allow-Origin") != server.URL {
+		t.Fatalf("same origin is not allowed: %v", resp.Header)
+	}
+
+	u.AllowedOrigins = []string{"*"}
+	resp := preflight("https://evil.com")
+	if resp.Header.Get("Access-Control-Allow-Origin") != "*" || resp.Header.Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("unexpected headers: %v", resp.Header)
+	}
+}
+
+func TestHTTPBuffer(t *testing.T) {
+	c := newHTTPConn("sid", TransportPolling, "", 4, func(string) {})
+	if _, err := c.Write([]byte("hell")); err != nil {
+		t.Fatal(err)
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := c.Write([]byte("o"))
+		written <- err
+	}()
+	select {
+	case <-written:
+		t.Fatal("write is not blocked by the full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if data := c.drain(); string(data) != "hell" {
+		t.Fatalf("unexpected data: %s", data)
+	}
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+
+	// data larger than the buffer is queued if the buffer is empty
+	c.drain()
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_, err := c.Write([]byte("!"))
+		written <- err
+	}()
+	c.Close()
+	if err := <-written; err != io.ErrClosedPipe {
+		t.Fatalf("blocked write is not closed: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package upgrader
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// checkOrigin reports whether the origin of request is allowed, only the same
+// origin is allowed if the list is empty, and an allowed origin can be "*" for
+// all origins, a host, a full origin or a wildcard host, such as: example.com,
+// https://example.com:8443, *.example.com
+func checkOrigin(allowed []string, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// not a browser request
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		switch {
+		case a == "*":
+			return true
+		case strings.Contains(a, "://"):
+			if a == strings.ToLower(origin) {
+				return true
+			}
+		case strings.HasPrefix(a, "*."):
+			if strings.HasSuffix(host, a[1:]) {
+				return true
+			}
+		case a == host:
+			return true
+		}
+	}
+	return false
+}
+
+// allowAll reports whether all origins are allowed
+func allowAll(allowed []string) bool {
+	for _, a := range allowed {
+		if a == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package upgrader
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	for _, c := range []struct {
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{nil, "", true},
+		{nil, "http://game.com:8080", true},
+		{nil, "http://game.com", false},
+		{nil, "https://evil.com", false},
+		{[]string{"*"}, "https://evil.com", true},
+		{[]string{"example.com"}, "https://example.com:8443", true},
+		{[]string{"example.com"}, "https://example.com.evil.com", false},
+		{[]string{"*.example.com"}, "https://game.example.com:8443", true},
+		{[]string{"*.example.com"}, "https://example.com", false},
+		{[]string{"https://example.com:8443"}, "https://example.com:8443", true},
+		{[]string{"https://example.com:8443"}, "https://example.com", false},
+	} {
+		r := httptest.NewRequest("GET", "http://game.com:8080/", nil)
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if got := checkOrigin(c.allowed, r); got != c.want {
+			t.Fatalf("origin %s allowed by %v: got %v, want %v", c.origin, c.allowed, got, c.want)
+		}
+	}
+}
@@ -5,6 +5,16 @@ import (
 	"net/http"
 )
 
+// Upgrader upgrades HTTP requests to net.Conn, which is served as a client
+// connection of the node, a nil connection without error is returned if the
+// request is served by an existing connection, such as HTTP long-polling
 type Upgrader interface {
 	Upgrade(w http.ResponseWriter, r *http.Request, params map[string]string) (net.Conn, error)
 }
+
+// Opener is implemented by upgraders serving requests by existing connections,
+// which reports whether the request opens a new connection, requests not
+// opening connections are neither authenticated nor counted by admission
+type Opener interface {
+	Opens(r *http.Request) bool
+}
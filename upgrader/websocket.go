@@ -0,0 +1,196 @@
+package upgrader
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket is an Upgrader implementation based on gorilla/websocket, each
+// binary frame carries a part of the codec stream
+type WebSocket struct {
+	websocket.Upgrader
+
+	// AllowedOrigins is the list of origins allowed to connect, only the same
+	// origin is allowed if empty and all origins are allowed by "*", it's
+	// ignored if the CheckOrigin is set
+	AllowedOrigins []string
+
+	// PingInterval is the interval of pings sent by server, the connection is
+	// closed if nothing is received in PongWait, keepalive is disabled if zero
+	PingInterval time.Duration
+	PongWait     time.Duration
+
+	// CompressionLevel is the flate compression level of messages when the
+	// EnableCompression is set, the default level is used if zero
+	CompressionLevel int
+}
+
+// NewWebSocket returns a WebSocket upgrader with default buffer sizes, which
+// accepts the same origin only
+func NewWebSocket() *WebSocket {
+	return &WebSocket{
+		Upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+}
+
+// Upgrade upgrades the HTTP connection to WebSocket, and returns it as net.Conn
+func (u *WebSocket) Upgrade(w http.ResponseWriter, r *http.Request, params map[string]string) (net.Conn, error) {
+	upgrader := u.Upgrader
+	if upgrader.CheckOrigin == nil {
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			return checkOrigin(u.AllowedOrigins, r)
+		}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if upgrader.EnableCompression {
+		conn.EnableWriteCompression(true)
+		if u.CompressionLevel != 0 {
+			if err := conn.SetCompressionLevel(u.CompressionLevel); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+	}
+
+	c := NewWebSocketConn(conn)
+	if u.PingInterval > 0 {
+		wait := u.PongWait
+		if wait <= 0 {
+			wait = 2 * u.PingInterval
+		}
+		c.keepalive(u.PingInterval, wait)
+	}
+	return c, nil
+}
+
+// WebSocketConn adapts websocket.Conn to net.Conn, data is written in binary
+// frames and frames are read as a continuous stream
+type WebSocketConn struct {
+	conn     *websocket.Conn
+	reader   io.Reader
+	wmu      sync.Mutex // websocket.Conn supports only one concurrent writer
+	pongWait time.Duration
+	die      chan struct{}
+	dieOnce  sync.Once
+}
+
+// NewWebSocketConn wraps the WebSocket connection
+func NewWebSocketConn(conn *websocket.Conn) *WebSocketConn {
+	return &WebSocketConn{conn: conn, die: make(chan struct{})}
+}
+
+// keepalive sends pings in interval, and extends the read deadline when any
+// frame or pong is received
+func (c *WebSocketConn) keepalive(interval, wait time.Duration) {
+	c.pongWait = wait
+	c.conn.SetReadDeadline(time.Now().Add(wait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wait))
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				deadline := time.Now().Add(interval)
+				if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+					return
+				}
+			case <-c.die:
+				return
+			}
+		}
+	}()
+}
+
+// Read reads data from frames, the frame boundaries are not preserved
+func (c *WebSocketConn) Read(b []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			typ, r, err := c.conn.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			if c.pongWait > 0 {
+				c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+			}
+			if typ != websocket.BinaryMessage && typ != websocket.TextMessage {
+				continue
+			}
+			c.reader = r
+		}
+
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Write writes data in a binary frame
+func (c *WebSocketConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if err := c.conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes the underlying connection
+func (c *WebSocketConn) Close() error {
+	c.dieOnce.Do(func() { close(c.die) })
+	return c.conn.Close()
+}
+
+// LocalAddr returns the local network address
+func (c *WebSocketConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *WebSocketConn) SetDeadline(t time.Time) error {
+	if err := c.conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
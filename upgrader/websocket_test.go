@@ -0,0 +1,85 @@
+package upgrader
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serve echoes the data of connections returned by upgrader
+func serve(u Upgrader) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil || conn == nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}(conn)
+	}))
+}
+
+func TestWebSocket(t *testing.T) {
+	u := NewWebSocket()
+	u.AllowedOrigins = []string{"*.example.com"}
+	u.EnableCompression = true
+	u.PingInterval = 50 * time.Millisecond
+	server := serve(u)
+	defer server.Close()
+
+	addr := "ws" + strings.TrimPrefix(server.URL, "http")
+	header := http.Header{}
+	header.Set("Origin", "https://evil.com")
+	if _, _, err := websocket.DefaultDialer.Dial(addr, header); err == nil {
+		t.Fatal("origin should be rejected")
+	}
+
+	header.Set("Origin", "https://game.example.com")
+	dialer := &websocket.Dialer{EnableCompression: true}
+	ws, _, err := dialer.Dial(addr, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chPing := make(chan struct{}, 1)
+	ws.SetPingHandler(func(string) error {
+		select {
+		case chPing <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	conn := NewWebSocketConn(ws)
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 3)
+	var data []byte
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(data) < 5 {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, buf[:n]...)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("unexpected data: %s", data)
+	}
+
+	// control frames are handled while reading
+	go conn.Read(make([]byte, 16))
+	select {
+	case <-chPing:
+	case <-time.After(time.Second):
+		t.Fatal("ping not received")
+	}
+}